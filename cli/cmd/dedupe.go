@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mhborthwick/medley/cli/pkg/spotify"
+	"github.com/mhborthwick/medley/cli/pkg/state"
+)
+
+// dedupePlaylist removes the repeats of tracks in a playlist, matched by
+// rule and keeping the occurrence picked by keep. With dryRun it only
+// prints what it would remove.
+func dedupePlaylist(spotifyClient spotify.Spotify, stateDir, playlistID string, rule spotify.DedupeRule, keep string, dryRun bool) error {
+	// the snapshot id pins the positions
+	// below to the playlist as it is now
+	snapshotID, err := spotifyClient.GetSnapshotID(playlistID)
+	if err != nil {
+		return err
+	}
+	items, err := spotifyClient.GetAllItems(playlistID)
+	if err != nil {
+		return err
+	}
+	groups, err := spotify.FindDuplicates(items, rule, keep)
+	if err != nil {
+		return err
+	}
+
+	removing := 0
+	for _, g := range groups {
+		fmt.Printf("keeping %d: %s\n", g.Keep+1, items[g.Keep].Track)
+		for _, p := range g.Remove {
+			fmt.Printf("  removing %d: %s\n", p+1, items[p].Track)
+		}
+		removing += len(g.Remove)
+	}
+	fmt.Println("removing", removing, "of", len(items), "tracks")
+	if dryRun || removing == 0 {
+		return nil
+	}
+
+	// save the playlist as it is before changing it,
+	// so it can be put back with medley restore
+	st, _, err := state.Load(stateDir, playlistID)
+	if err != nil {
+		return err
+	}
+	snapshot, err := state.SaveSnapshot(stateDir, playlistID, snapshotID, items, st.OwnedOf(items), time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Println("Snapshot:", snapshot.ID)
+
+	// every batch is planned in a journal first, so an
+	// interrupted dedupe can be finished with medley resume
+	journal := state.NewJournal("dedupe", "", playlistID, time.Now())
+	size := len(items)
+	for _, batch := range spotify.DeleteBatches(items, groups) {
+		journal.Ops = append(journal.Ops, state.Op{Kind: state.OpRemovePositions, Positions: batch, Size: size})
+		for _, t := range batch {
+			size -= len(t.Positions)
+		}
+	}
+	journal.Ops[0].SnapshotID = snapshotID
+	if err := journal.Save(stateDir); err != nil {
+		return err
+	}
+	return runJournal(spotifyClient, stateDir, journal, false)
+}
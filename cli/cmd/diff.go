@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mhborthwick/medley/cli/pkg/spotify"
+)
+
+// diffPlaylists prints the tracks only in playlist a, only in playlist
+// b and in both, matched by match. With ordered it also prints the
+// tracks of both that are in a different order, at their positions.
+func diffPlaylists(spotifyClient spotify.Spotify, a, b, match string, ordered bool) error {
+	itemsA, err := spotifyClient.GetAllItems(a)
+	if err != nil {
+		return err
+	}
+	itemsB, err := spotifyClient.GetAllItems(b)
+	if err != nil {
+		return err
+	}
+	diff, err := spotify.DiffPlaylists(itemsA, itemsB, match, ordered)
+	if err != nil {
+		return err
+	}
+
+	printItems := func(heading string, items []spotify.PlaylistItem) {
+		fmt.Printf("%s (%d):\n", heading, len(items))
+		for _, item := range items {
+			fmt.Println(" ", item.Track)
+		}
+	}
+	printItems("Only in "+a, diff.OnlyA)
+	printItems("Only in "+b, diff.OnlyB)
+	printItems("In both", diff.Both)
+	if ordered {
+		fmt.Printf("Moved (%d):\n", len(diff.Moved))
+		for _, m := range diff.Moved {
+			fmt.Printf("  %s: %d -> %d\n", m.Track, m.From+1, m.To+1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mhborthwick/medley/cli/pkg/spotify"
+)
+
+// fakeSpotify serves the playlist endpoints medley uses from
+// playlists held in memory, changing them like Spotify would.
+type fakeSpotify struct {
+	*httptest.Server
+	mu        sync.Mutex
+	playlists map[string][]spotify.PlaylistItem
+	versions  map[string]int
+	// fail, if set, fails the requests it returns true for
+	fail func(r *http.Request) bool
+}
+
+func newFakeSpotify(playlists map[string][]spotify.PlaylistItem) *fakeSpotify {
+	f := &fakeSpotify{playlists: playlists, versions: make(map[string]int)}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+func (f *fakeSpotify) client() spotify.Spotify {
+	return spotify.Spotify{URL: f.URL, Token: "token", UserID: "me", Client: &http.Client{}}
+}
+
+// uris returns the uris of a playlist, in order.
+func (f *fakeSpotify) uris(id string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var uris []string
+	for _, item := range f.playlists[id] {
+		uris = append(uris, item.Track.URI)
+	}
+	return uris
+}
+
+func (f *fakeSpotify) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if f.fail != nil && f.fail(r) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "server error"}`))
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/"), "/")
+	switch {
+	case parts[0] == "users" && r.Method == "POST":
+		id := fmt.Sprintf("created%015d", len(f.playlists))
+		f.playlists[id] = nil
+		json.NewEncoder(w).Encode(spotify.CreatePlaylistResponseBody{ID: id})
+	case parts[0] == "tracks":
+		var body spotify.GetTracksResponseBody
+		for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+			body.Tracks = append(body.Tracks, &spotify.Track{URI: "spotify:track:" + id})
+		}
+		json.NewEncoder(w).Encode(body)
+	case len(parts) == 2:
+		f.snapshot(w, parts[1], false)
+	case r.Method == "GET":
+		f.page(w, r, parts[1])
+	case r.Method == "POST":
+		var body spotify.AddItemsToPlaylistRequestBody
+		json.NewDecoder(r.Body).Decode(&body)
+		var added []spotify.PlaylistItem
+		for _, uri := range body.URIs {
+			added = append(added, spotify.PlaylistItem{
+				AddedAt: time.Now().UTC().Format(time.RFC3339),
+				AddedBy: spotify.User{ID: "me"},
+				Track:   spotify.Track{URI: uri},
+			})
+		}
+		at := len(f.playlists[parts[1]])
+		if body.Position != nil {
+			at = *body.Position
+		}
+		f.playlists[parts[1]] = slices.Insert(f.playlists[parts[1]], at, added...)
+		f.snapshot(w, parts[1], true)
+	case r.Method == "DELETE":
+		var body spotify.DeleteItemsAtPositionsRequestBody
+		json.NewDecoder(r.Body).Decode(&body)
+		items := f.playlists[parts[1]]
+		remove := make(map[int]bool)
+		for _, t := range body.Tracks {
+			for i, item := range items {
+				if item.Track.URI == t.URI && (t.Positions == nil || slices.Contains(t.Positions, i)) {
+					remove[i] = true
+				}
+			}
+		}
+		var kept []spotify.PlaylistItem
+		for i, item := range items {
+			if !remove[i] {
+				kept = append(kept, item)
+			}
+		}
+		f.playlists[parts[1]] = kept
+		f.snapshot(w, parts[1], true)
+	case r.Method == "PUT":
+		var body spotify.ReorderPlaylistItemsRequestBody
+		json.NewDecoder(r.Body).Decode(&body)
+		items := slices.Clone(f.playlists[parts[1]])
+		moved := slices.Clone(items[body.RangeStart : body.RangeStart+body.RangeLength])
+		at := body.InsertBefore
+		if at > body.RangeStart {
+			at -= body.RangeLength
+		}
+		items = slices.Delete(items, body.RangeStart, body.RangeStart+body.RangeLength)
+		f.playlists[parts[1]] = slices.Insert(items, at, moved...)
+		f.snapshot(w, parts[1], true)
+	}
+}
+
+// snapshot writes the snapshot id of a playlist, which changes
+// with every request that changes the playlist.
+func (f *fakeSpotify) snapshot(w http.ResponseWriter, id string, changed bool) {
+	if changed {
+		f.versions[id]++
+	}
+	json.NewEncoder(w).Encode(spotify.SnapshotResponseBody{SnapshotID: strconv.Itoa(f.versions[id])})
+}
+
+// page writes the items of a playlist from the offset asked for.
+func (f *fakeSpotify) page(w http.ResponseWriter, r *http.Request, id string) {
+	items := f.playlists[id]
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, _ = strconv.Atoi(l)
+	}
+	end := min(offset+limit, len(items))
+	offset = min(offset, end)
+	var next *string
+	if end < len(items) {
+		url := fmt.Sprintf("%s/v1/playlists/%s/tracks?offset=%d&limit=%d", f.URL, id, end, limit)
+		next = &url
+	}
+	json.NewEncoder(w).Encode(struct {
+		Items []spotify.PlaylistItem `json:"items"`
+		Total int                    `json:"total"`
+		Next  *string                `json:"next"`
+	}{Items: items[offset:end], Total: len(items), Next: next})
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mhborthwick/medley/cli/pkg/spotify"
+	"github.com/mhborthwick/medley/cli/pkg/state"
+)
+
+// runJournal runs the operations of j that aren't done yet, recording
+// each one as it completes. When resuming, the first of them may have
+// gone through before the run was interrupted, so tracks it would add
+// that are already in the destination are skipped. State is only kept
+// for destinations that have some, so a restore of a playlist medley
+// doesn't manage leaves it that way.
+func runJournal(spotifyClient spotify.Spotify, stateDir string, j *state.Journal, resuming bool) error {
+	if j.UserID != "" {
+		spotifyClient.UserID = j.UserID
+	}
+	var st *state.State
+	if j.Destination != "" {
+		loaded, ok, err := state.Load(stateDir, j.Destination)
+		if err != nil {
+			return err
+		}
+		if ok {
+			st = loaded
+		}
+	}
+	for i := range j.Ops {
+		op := &j.Ops[i]
+		if op.Done {
+			continue
+		}
+		switch op.Kind {
+		case state.OpCreate:
+			playlistID, err := spotifyClient.CreatePlaylist()
+			if err != nil {
+				return err
+			}
+			j.Destination = playlistID
+			if st, _, err = state.Load(stateDir, playlistID); err != nil {
+				return err
+			}
+		case state.OpAdd:
+			uris := op.URIs
+			if resuming {
+				var err error
+				if uris, err = missingFrom(spotifyClient, j.Destination, uris); err != nil {
+					return err
+				}
+			}
+			if len(uris) > 0 {
+				if _, err := spotifyClient.AddItemsToPlaylist(uris, j.Destination, op.Prepend); err != nil {
+					return err
+				}
+			}
+			if st != nil {
+				for _, uri := range op.Owned {
+					st.Add(uri, op.Sources[uri], time.Now())
+				}
+				if err := st.Save(stateDir); err != nil {
+					return err
+				}
+			}
+		case state.OpRemove:
+			if _, err := spotifyClient.DeleteItemsFromPlaylist(op.URIs, j.Destination); err != nil {
+				return err
+			}
+			if st != nil {
+				st.Remove(op.URIs)
+				if err := st.Save(stateDir); err != nil {
+					return err
+				}
+			}
+		case state.OpArchive:
+			if err := archiveTracks(spotifyClient, op.Playlist, op.URIs); err != nil {
+				return err
+			}
+		case state.OpReorder:
+			if err := reorderPlaylist(spotifyClient, j.Destination, op.URIs, op.Pins); err != nil {
+				return err
+			}
+		case state.OpRemovePositions:
+			snapshotID, err := removePositions(spotifyClient, j.Destination, *op, resuming)
+			if err != nil {
+				return err
+			}
+			// positions of the next op are in the
+			// playlist as this one leaves it
+			if i+1 < len(j.Ops) && j.Ops[i+1].Kind == state.OpRemovePositions {
+				j.Ops[i+1].SnapshotID = snapshotID
+			}
+		case state.OpRestoreOrder:
+			if err := restoreOrder(spotifyClient, j.Destination, op.URIs); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown journal operation: %s", op.Kind)
+		}
+		op.Done = true
+		resuming = false
+		if err := j.Save(stateDir); err != nil {
+			return err
+		}
+	}
+	return j.Finish(stateDir)
+}
+
+// removePositions runs a removePositions op and returns the snapshot
+// the playlist is at after it. When resuming, the op may have gone
+// through already, which the playlist having the tracks left after it
+// tells apart from the playlist having been changed since.
+func removePositions(spotifyClient spotify.Spotify, playlistID string, op state.Op, resuming bool) (string, error) {
+	if resuming {
+		snapshotID, err := spotifyClient.GetSnapshotID(playlistID)
+		if err != nil {
+			return "", err
+		}
+		if snapshotID != op.SnapshotID {
+			items, err := spotifyClient.GetAllItems(playlistID)
+			if err != nil {
+				return "", err
+			}
+			removing := 0
+			for _, t := range op.Positions {
+				removing += len(t.Positions)
+			}
+			if len(items) != op.Size-removing {
+				return "", fmt.Errorf("playlist %s changed since it was interrupted, run it again", playlistID)
+			}
+			return snapshotID, nil
+		}
+	}
+	return spotifyClient.DeleteItemsAtPositions(playlistID, op.Positions, op.SnapshotID)
+}
+
+// missingFrom returns the uris that aren't in the playlist yet.
+func missingFrom(spotifyClient spotify.Spotify, playlistID string, uris []string) ([]string, error) {
+	items, err := spotifyClient.GetAllItems(playlistID)
+	if err != nil {
+		return nil, err
+	}
+	present := make(map[string]bool)
+	for _, item := range items {
+		present[item.Track.URI] = true
+	}
+	var missing []string
+	for _, uri := range uris {
+		if !present[uri] {
+			missing = append(missing, uri)
+		}
+	}
+	return missing, nil
+}
+
+// resumeJournals finishes the interrupted runs that match,
+// and returns them.
+func resumeJournals(spotifyClient spotify.Spotify, stateDir string, match func(j *state.Journal) bool) ([]*state.Journal, error) {
+	pending, err := state.Pending(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	var resumed []*state.Journal
+	for _, j := range pending {
+		if !match(j) {
+			continue
+		}
+		fmt.Println("Resuming:", j.Command, j.Path, "started", j.StartedAt.Format(time.RFC3339))
+		if err := runJournal(spotifyClient, stateDir, j, true); err != nil {
+			return nil, err
+		}
+		fmt.Println("Playlist:", "https://open.spotify.com/playlist/"+j.Destination)
+		resumed = append(resumed, j)
+	}
+	return resumed, nil
+}
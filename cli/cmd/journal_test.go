@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mhborthwick/medley/cli/pkg/spotify"
+	"github.com/mhborthwick/medley/cli/pkg/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunJournal(t *testing.T) {
+	t.Run("leaves failed op to resume", func(t *testing.T) {
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error": "server error"}`))
+		}))
+		defer mockServer.Close()
+		spotifyClient := spotify.Spotify{URL: mockServer.URL, Token: "token", Client: &http.Client{}}
+		stateDir := t.TempDir()
+		j := state.NewJournal("sync", "medleys.pkl", "123", time.Now())
+		j.Ops = []state.Op{{Kind: state.OpAdd, URIs: []string{"a"}}}
+		assert.Nil(t, j.Save(stateDir))
+
+		err := runJournal(spotifyClient, stateDir, j, false)
+		assert.EqualError(t, err, `add playlist items: 500 Internal Server Error: {"error": "server error"}`)
+		pending, err := state.Pending(stateDir)
+		assert.Nil(t, err)
+		assert.Len(t, pending, 1)
+		assert.False(t, pending[0].Ops[0].Done)
+	})
+
+	t.Run("resumes an add that went through in part", func(t *testing.T) {
+		destination := "dddddddddddddddddddddd"
+		item := spotify.PlaylistItem{AddedBy: spotify.User{ID: "me"}, Track: spotify.Track{URI: "a"}}
+		// a was added before the run was interrupted
+		fake := newFakeSpotify(map[string][]spotify.PlaylistItem{destination: {item}})
+		defer fake.Close()
+		stateDir := t.TempDir()
+		st, _, err := state.Load(stateDir, destination)
+		assert.Nil(t, err)
+		assert.Nil(t, st.Save(stateDir))
+		j := state.NewJournal("sync", "medleys.pkl", destination, time.Now())
+		j.Ops = []state.Op{{Kind: state.OpAdd, URIs: []string{"a", "b"}, Sources: map[string]string{"a": "s", "b": "s"}, Owned: []string{"a", "b"}}}
+		assert.Nil(t, j.Save(stateDir))
+
+		resumed, err := resumeJournals(fake.client(), stateDir, func(j *state.Journal) bool { return true })
+		assert.Nil(t, err)
+		assert.Len(t, resumed, 1)
+		assert.Equal(t, []string{"a", "b"}, fake.uris(destination))
+		pending, err := state.Pending(stateDir)
+		assert.Nil(t, err)
+		assert.Empty(t, pending)
+		st, _, err = state.Load(stateDir, destination)
+		assert.Nil(t, err)
+		assert.True(t, st.Owns("a"))
+		assert.True(t, st.Owns("b"))
+	})
+}
+
+func TestRemovePositions(t *testing.T) {
+	// serves a playlist of two tracks at snapshot, counting deletes
+	newServer := func(snapshot string, deletes *int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			switch {
+			case r.Method == "DELETE":
+				*deletes++
+				w.Write([]byte(`{"snapshot_id": "after"}`))
+			case r.URL.Query().Get("fields") == "snapshot_id":
+				w.Write([]byte(`{"snapshot_id": "` + snapshot + `"}`))
+			default:
+				w.Write([]byte(`{"items": [{"track": {"uri": "a"}}, {"track": {"uri": "b"}}], "next": null}`))
+			}
+		}))
+	}
+	op := state.Op{
+		Kind:       state.OpRemovePositions,
+		Positions:  []spotify.PositionedTrack{{URI: "a", Positions: []int{2}}},
+		SnapshotID: "before",
+		Size:       3,
+	}
+
+	t.Run("removes tracks at positions", func(t *testing.T) {
+		deletes := 0
+		mockServer := newServer("before", &deletes)
+		defer mockServer.Close()
+		spotifyClient := spotify.Spotify{URL: mockServer.URL, Token: "token", Client: &http.Client{}}
+		snapshotID, err := removePositions(spotifyClient, "123", op, true)
+		assert.Nil(t, err)
+		assert.Equal(t, "after", snapshotID)
+		assert.Equal(t, 1, deletes)
+	})
+
+	t.Run("skips removal that went through before resuming", func(t *testing.T) {
+		deletes := 0
+		mockServer := newServer("removed", &deletes)
+		defer mockServer.Close()
+		spotifyClient := spotify.Spotify{URL: mockServer.URL, Token: "token", Client: &http.Client{}}
+		snapshotID, err := removePositions(spotifyClient, "123", op, true)
+		assert.Nil(t, err)
+		assert.Equal(t, "removed", snapshotID)
+		assert.Equal(t, 0, deletes)
+	})
+
+	t.Run("returns error if playlist changed otherwise", func(t *testing.T) {
+		deletes := 0
+		mockServer := newServer("edited", &deletes)
+		defer mockServer.Close()
+		spotifyClient := spotify.Spotify{URL: mockServer.URL, Token: "token", Client: &http.Client{}}
+		changed := op
+		changed.Size = 4
+		_, err := removePositions(spotifyClient, "123", changed, true)
+		assert.EqualError(t, err, "playlist 123 changed since it was interrupted, run it again")
+		assert.Equal(t, 0, deletes)
+	})
+}
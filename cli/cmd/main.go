@@ -13,6 +13,7 @@ import (
 	"github.com/alecthomas/kong"
 	"github.com/apple/pkl-go/pkl"
 	"github.com/mhborthwick/medley/cli/pkg/spotify"
+	"github.com/mhborthwick/medley/cli/pkg/state"
 )
 
 var CLI struct {
@@ -20,8 +21,46 @@ var CLI struct {
 		Path string `arg:"" name:"path" help:"Path to pkl file." type:"path"`
 	} `cmd:"" help:"Create playlist."`
 	Sync struct {
-		Path string `arg:"" name:"path" help:"Path to pkl file." type:"path"`
+		Path            string `arg:"" name:"path" help:"Path to pkl file." type:"path"`
+		Name            string `arg:"" name:"name" optional:"" help:"Name of the medley to sync, if the file declares several."`
+		All             bool   `help:"Sync every medley declared in the file."`
+		AllowMassDelete bool   `help:"Allow removing more tracks than maxDelete/maxDeletePercent, or syncing with empty sources."`
 	} `cmd:"" help:"Sync playlist."`
+	Why struct {
+		Track string `arg:"" name:"track" help:"Track URI or link."`
+	} `cmd:"" help:"Explain why a track is in a synced playlist."`
+	Snapshots struct {
+		Playlist string `arg:"" name:"playlist" help:"Playlist ID or link."`
+	} `cmd:"" help:"List the last snapshots taken of a playlist before it was changed."`
+	Restore struct {
+		Playlist string `arg:"" name:"playlist" help:"Playlist ID or link."`
+		Snapshot string `arg:"" name:"snapshot" help:"ID of the snapshot to restore, from medley snapshots."`
+	} `cmd:"" help:"Put a playlist back the way it was in a snapshot."`
+	Resume struct {
+	} `cmd:"" help:"Finish creates, syncs, restores and dedupes that were interrupted."`
+	Dedupe struct {
+		Playlist   string  `arg:"" name:"playlist" help:"Playlist ID or link."`
+		By         string  `enum:"uri,isrc,title,fuzzy" default:"uri" help:"Match tracks by uri, isrc, normalized title and artist, or similar title and artist."`
+		Similarity float64 `default:"0.85" help:"How alike, from 0 to 1, titles and artists have to be with --by fuzzy."`
+		Keep       string  `enum:"first,oldest" default:"first" help:"Keep the first occurrence or the one added first."`
+		DryRun     bool    `help:"Only print the duplicates that would be removed."`
+	} `cmd:"" help:"Remove repeated tracks from a playlist."`
+	Watch struct {
+		Path            string `arg:"" name:"path" help:"Path to pkl file." type:"path"`
+		AllowMassDelete bool   `help:"Allow removing more tracks than maxDelete/maxDeletePercent, or syncing with empty sources."`
+	} `cmd:"" help:"Sync medleys on their schedules, reloading the file when it or a module it imports changes."`
+	Stats struct {
+		Path   string `arg:"" name:"path" help:"Path to pkl file." type:"path"`
+		Name   string `arg:"" name:"name" optional:"" help:"Name of the medley, if the file declares several."`
+		Top    int    `default:"10" help:"How many of the top artists to list."`
+		Format string `enum:"table,json" default:"table" help:"Print tables or json."`
+	} `cmd:"" help:"Show what each source contributes to a medley and how much they overlap."`
+	Diff struct {
+		PlaylistA string `arg:"" name:"playlistA" help:"Playlist ID or link."`
+		PlaylistB string `arg:"" name:"playlistB" help:"Playlist ID or link."`
+		By        string `enum:"uri,isrc" default:"uri" help:"Match tracks by uri or isrc."`
+		Ordered   bool   `help:"Also show tracks that are in both but in a different order."`
+	} `cmd:"" help:"Compare the tracks of two playlists."`
 }
 
 func handleError(err error) {
@@ -58,6 +97,22 @@ func GetToken() (string, error) {
 	return parsed.AccessToken, nil
 }
 
+// newClient returns a client of the Spotify API,
+// with a token from the authserver.
+func newClient(userID, market string) (spotify.Spotify, error) {
+	token, err := GetToken()
+	if err != nil {
+		return spotify.Spotify{}, err
+	}
+	return spotify.Spotify{
+		URL:    "https://api.spotify.com",
+		Token:  token,
+		UserID: userID,
+		Client: &http.Client{},
+		Market: market,
+	}, nil
+}
+
 func main() {
 	ctx := kong.Parse(&CLI)
 	evaluator, err := pkl.NewEvaluator(context.Background(), pkl.PreconfiguredOptions)
@@ -75,39 +130,36 @@ func main() {
 			panic(err)
 		}
 
-		// get token from authserver
-		token, err := GetToken()
+		spotifyClient, err := newClient(cfg.UserID, cfg.Dedupe.Market())
 		handleError(err)
 
-		spotifyClient := spotify.Spotify{
-			URL:    "https://api.spotify.com",
-			Token:  token,
-			UserID: cfg.UserID,
-			Client: &http.Client{},
-		}
-
-		var all []string
+		stateDir, err := state.Dir()
+		handleError(err)
 
-		for _, p := range cfg.Playlists {
-			id, err := spotify.GetID(p)
-			handleError(err)
-			baseURL := fmt.Sprintf("%s/v1/playlists/%s/tracks", spotifyClient.URL, id)
-			nextURL := baseURL
-			// you have to paginate these requests
-			// because spotify caps you at 20 songs per request
-			for nextURL != "" {
-				body, err := spotifyClient.GetPlaylistItems(nextURL)
-				handleError(err)
-				uris, err := spotify.GetURIs(body)
-				handleError(err)
-				all = append(all, uris...)
-				nextURL, err = spotify.GetNextURL(body)
-				handleError(err)
-			}
+		// a create of the same file that was interrupted
+		// is finished instead of creating a second playlist
+		resumed, err := resumeJournals(spotifyClient, stateDir, func(j *state.Journal) bool {
+			return j.Command == "create" && j.Path == CLI.Create.Path
+		})
+		handleError(err)
+		if len(resumed) > 0 {
+			fmt.Println("Created in:", time.Since(startNow))
+			return
 		}
 
-		playlistID, err := spotifyClient.CreatePlaylist()
+		// get all uris from the evaluated source, the same
+		// way sync does for a medley without a destination yet
+		m := spotify.Medley{Config: spotify.SyncConfig{MedleyConfig: cfg.MedleyConfig}}
+		s := newSyncer(spotify.NewPlaylistCache(spotifyClient), evaluator, CLI.Create.Path, nil)
+		items, _, err := s.evaluate(m)
 		handleError(err)
+		var all []string
+		// the source each uri was found in
+		sourceOf := make(map[string]string)
+		for _, item := range items {
+			all = append(all, item.Track.URI)
+			sourceOf[item.Track.URI] = item.Source
+		}
 
 		// cleans duplicate songs
 		uniqueURIsMap := make(map[string]bool)
@@ -119,28 +171,35 @@ func main() {
 			}
 		}
 
-		var payloads [][]string
+		// pinned tracks are added at their positions
+		unique, err = spotify.PinTracks(unique, cfg.Pinned)
+		handleError(err)
 
-		// creates multiple payloads with <=100 songs to send in batches
-		// because spotify caps you at 100 songs per request
-		for len(unique) > 0 {
-			var payload []string
-			if len(unique) >= 100 {
-				payload, unique = unique[:100], unique[100:]
-			} else {
-				payload, unique = unique, nil
-			}
-			payloads = append(payloads, payload)
-		}
+		// creates multiple payloads to send in batches
+		// because spotify caps how many songs it takes per request
+		payloads := spotify.Batches(unique, spotify.BatchSize)
 
+		// every step is planned in a journal first, so an
+		// interrupted create can be finished with medley resume
+		journal := state.NewJournal("create", CLI.Create.Path, "", time.Now())
+		journal.UserID = cfg.UserID
+		journal.Ops = append(journal.Ops, state.Op{Kind: state.OpCreate})
 		for _, p := range payloads {
-			_, err = spotifyClient.AddItemsToPlaylist(p, playlistID, false)
-			handleError(err)
+			// record what medley added so later syncs
+			// can tell it apart from tracks added by hand
+			from := make(map[string]string)
+			for _, uri := range p {
+				from[uri] = sourceOf[uri]
+			}
+			journal.Ops = append(journal.Ops, state.Op{Kind: state.OpAdd, URIs: p, Sources: from, Owned: p})
 		}
+		handleError(journal.Save(stateDir))
+		handleError(runJournal(spotifyClient, stateDir, journal, false))
+		playlistID := journal.Destination
 
 		fmt.Println("Playlist:", "https://open.spotify.com/playlist/"+playlistID)
 		fmt.Println("Created in:", time.Since(startNow))
-	case "sync <path>":
+	case "sync <path>", "sync <path> <name>":
 		startNow := time.Now()
 		fmt.Println("Evaluating from: " + CLI.Sync.Path)
 
@@ -149,144 +208,160 @@ func main() {
 			panic(err)
 		}
 
-		// get token from authserver
-		token, err := GetToken()
+		medleys, err := spotify.SelectMedleys(cfg, CLI.Sync.Name, CLI.Sync.All)
 		handleError(err)
 
-		spotifyClient := spotify.Spotify{
-			URL:    "https://api.spotify.com",
-			Token:  token,
-			UserID: cfg.UserID,
-			Client: &http.Client{},
-		}
-
-		// get all uris from target playlist
-		var target []string
-
-		id, err := spotify.GetID(cfg.Destination)
-		handleError(err)
-		baseURL := fmt.Sprintf("%s/v1/playlists/%s/tracks", spotifyClient.URL, id)
-		nextURL := baseURL
-		for nextURL != "" {
-			body, err := spotifyClient.GetPlaylistItems(nextURL)
-			handleError(err)
-			uris, err := spotify.GetURIs(body)
-			handleError(err)
-			target = append(target, uris...)
-			nextURL, err = spotify.GetNextURL(body)
-			handleError(err)
+		spotifyClient, err := newClient(cfg.UserID, market(medleys))
+		handleError(err)
+		// every medley of the file, for those read by the ones synced
+		all, err := spotify.SelectMedleys(cfg, "", len(cfg.Medleys) > 0)
+		handleError(err)
+		s := newSyncer(spotify.NewPlaylistCache(spotifyClient), evaluator, CLI.Sync.Path, all)
+		s.allowMassDelete = CLI.Sync.AllowMassDelete
+
+		// keep going when one medley fails, so the others
+		// still get synced, except those that read it
+		failed := 0
+		failedNames := make(map[string]bool)
+		for _, m := range medleys {
+			if len(medleys) > 1 {
+				fmt.Println("Syncing:", m.Name)
+			}
+			if i := slices.IndexFunc(m.Reads, func(name string) bool { return failedNames[name] }); i >= 0 {
+				fmt.Println("skipped, medley", m.Reads[i], "failed to sync")
+				failedNames[m.Name] = true
+				failed++
+				continue
+			}
+			if err := s.syncMedley(m); err != nil {
+				fmt.Println(err.Error())
+				failedNames[m.Name] = true
+				failed++
+				continue
+			}
+			fmt.Println("Playlist:", "https://open.spotify.com/playlist/"+m.Config.Destination)
 		}
-
-		// create target map
-		targetMap := make(map[string]bool)
-		for _, t := range target {
-			targetMap[t] = false
+		fmt.Println("Created in:", time.Since(startNow))
+		if failed > 0 {
+			handleError(fmt.Errorf("%d of %d medleys failed to sync", failed, len(medleys)))
 		}
+	case "why <track>":
+		id, err := spotify.GetID(CLI.Why.Track)
+		handleError(err)
+		uri := "spotify:track:" + id
 
-		// get all uris from provided playlists
-		var all []string
+		stateDir, err := state.Dir()
+		handleError(err)
+		all, err := state.LoadAll(stateDir)
+		handleError(err)
 
-		for _, p := range cfg.Playlists {
-			id, err := spotify.GetID(p)
-			handleError(err)
-			baseURL := fmt.Sprintf("%s/v1/playlists/%s/tracks", spotifyClient.URL, id)
-			nextURL := baseURL
-			// you have to paginate these requests
-			// because spotify caps you at 20 songs per request
-			for nextURL != "" {
-				body, err := spotifyClient.GetPlaylistItems(nextURL)
-				handleError(err)
-				uris, err := spotify.GetURIs(body)
-				handleError(err)
-				all = append(all, uris...)
-				nextURL, err = spotify.GetNextURL(body)
-				handleError(err)
+		found := false
+		for _, st := range all {
+			entry, ok := st.Tracks[uri]
+			if !ok {
+				continue
 			}
-		}
-
-		// if uri in target playlist
-		// set value to true
-		// if not, add to toAdd slice
-		toAdd := []string{}
-
-		for _, a := range all {
-			_, ok := targetMap[a]
-			if ok {
-				targetMap[a] = true
-			} else {
-				toAdd = append(toAdd, a)
+			found = true
+			source := "an existing playlist (adopted on first sync)"
+			if entry.Source != "" {
+				source = "https://open.spotify.com/playlist/" + entry.Source
 			}
+			fmt.Println("Playlist:", "https://open.spotify.com/playlist/"+st.Destination)
+			fmt.Println("  Source:", source)
+			fmt.Println("  Added:", entry.AddedAt.Format(time.RFC3339))
 		}
-
-		// cleans duplicate "toAdd songs"
-		// we won't need to clean "toRemove songs"
-		// because the way we evaluate them
-		uniqueToAddMap := make(map[string]bool)
-		uniqueToAdd := make([]string, 0, len(uniqueToAddMap))
-		for _, uri := range toAdd {
-			if _, found := uniqueToAddMap[uri]; !found {
-				uniqueToAddMap[uri] = true
-				uniqueToAdd = append(uniqueToAdd, uri)
-			}
+		if !found {
+			fmt.Println("Not added by medley to any playlist:", uri)
 		}
-
-		// creates multiple payloads with <=100 songs to send in batches
-		// because spotify caps you at 100 songs per request
-		var toAddPayloads [][]string
-
-		for len(uniqueToAdd) > 0 {
-			var payload []string
-			if len(uniqueToAdd) >= 100 {
-				payload, uniqueToAdd = uniqueToAdd[:100], uniqueToAdd[100:]
-			} else {
-				payload, uniqueToAdd = uniqueToAdd, nil
-			}
-			toAddPayloads = append(toAddPayloads, payload)
+	case "snapshots <playlist>":
+		id, err := spotify.GetID(CLI.Snapshots.Playlist)
+		handleError(err)
+		stateDir, err := state.Dir()
+		handleError(err)
+		snapshots, err := state.Snapshots(stateDir, id)
+		handleError(err)
+		if len(snapshots) == 0 {
+			fmt.Println("No snapshots of playlist:", id)
 		}
+		for _, s := range snapshots {
+			fmt.Println(s.ID, s.TakenAt.Format(time.RFC3339), len(s.Items), "tracks", s.SnapshotID)
+		}
+	case "restore <playlist> <snapshot>":
+		startNow := time.Now()
+		id, err := spotify.GetID(CLI.Restore.Playlist)
+		handleError(err)
+		stateDir, err := state.Dir()
+		handleError(err)
+		snapshot, err := state.LoadSnapshot(stateDir, id, CLI.Restore.Snapshot)
+		handleError(err)
 
-		fmt.Println("adding", toAddPayloads)
-
-		// get values still set to false
-		// these should be deleted
-		toRemove := []string{}
+		spotifyClient, err := newClient("", "")
+		handleError(err)
+		handleError(restorePlaylist(spotifyClient, stateDir, id, snapshot))
 
-		for k, v := range targetMap {
-			if !v {
-				toRemove = append(toRemove, k)
-			}
+		fmt.Println("Playlist:", "https://open.spotify.com/playlist/"+id)
+		fmt.Println("Restored in:", time.Since(startNow))
+	case "resume":
+		startNow := time.Now()
+		stateDir, err := state.Dir()
+		handleError(err)
+		pending, err := state.Pending(stateDir)
+		handleError(err)
+		if len(pending) == 0 {
+			fmt.Println("Nothing to resume")
+			return
 		}
 
-		// creates multiple payloads with <=100 songs to send in batches
-		// because spotify caps you at 100 songs per request
-		var toRemovePayloads [][]string
-		for len(toRemove) > 0 {
-			var payload []string
-			if len(toRemove) >= 100 {
-				payload, toRemove = toRemove[:100], toRemove[100:]
-			} else {
-				payload, toRemove = toRemove, nil
-			}
-			toRemovePayloads = append(toRemovePayloads, payload)
-		}
+		spotifyClient, err := newClient("", "")
+		handleError(err)
+		_, err = resumeJournals(spotifyClient, stateDir, func(j *state.Journal) bool { return true })
+		handleError(err)
+		fmt.Println("Resumed in:", time.Since(startNow))
+	case "dedupe <playlist>":
+		startNow := time.Now()
+		id, err := spotify.GetID(CLI.Dedupe.Playlist)
+		handleError(err)
+		stateDir, err := state.Dir()
+		handleError(err)
 
-		fmt.Println("removing", toRemovePayloads)
+		spotifyClient, err := newClient("", "")
+		handleError(err)
+		handleError(dedupePlaylist(spotifyClient, stateDir, id, spotify.DedupeRule{By: CLI.Dedupe.By, Similarity: &CLI.Dedupe.Similarity}, CLI.Dedupe.Keep, CLI.Dedupe.DryRun))
+
+		fmt.Println("Playlist:", "https://open.spotify.com/playlist/"+id)
+		fmt.Println("Deduped in:", time.Since(startNow))
+	case "watch <path>":
+		handleError(newWatcher(CLI.Watch.Path, CLI.Watch.AllowMassDelete).run())
+	case "diff <playlistA> <playlistB>":
+		a, err := spotify.GetID(CLI.Diff.PlaylistA)
+		handleError(err)
+		b, err := spotify.GetID(CLI.Diff.PlaylistB)
+		handleError(err)
 
-		// handle deletion
-		for _, p := range toRemovePayloads {
-			_, err = spotifyClient.DeleteItemsFromPlaylist(p, cfg.Destination)
-			handleError(err)
+		spotifyClient, err := newClient("", "")
+		handleError(err)
+		handleError(diffPlaylists(spotifyClient, a, b, CLI.Diff.By, CLI.Diff.Ordered))
+	case "stats <path>", "stats <path> <name>":
+		if CLI.Stats.Top < 0 {
+			handleError(fmt.Errorf("--top must be 0 or more, not %d", CLI.Stats.Top))
+		}
+		var cfg spotify.SyncConfig
+		if err = evaluator.EvaluateModule(context.Background(), pkl.FileSource(CLI.Stats.Path), &cfg); err != nil {
+			panic(err)
 		}
 
-		// reverse items in toAddPayloads
-		slices.Reverse(toAddPayloads)
+		medleys, err := spotify.SelectMedleys(cfg, CLI.Stats.Name, false)
+		handleError(err)
 
-		// handle addition
-		for _, p := range toAddPayloads {
-			_, err = spotifyClient.AddItemsToPlaylist(p, cfg.Destination, true)
-			handleError(err)
-		}
-		fmt.Println("Playlist:", "https://open.spotify.com/playlist/"+cfg.Destination)
-		fmt.Println("Created in:", time.Since(startNow))
+		spotifyClient, err := newClient(cfg.UserID, market(medleys))
+		handleError(err)
+		all, err := spotify.SelectMedleys(cfg, "", len(cfg.Medleys) > 0)
+		handleError(err)
+		s := newSyncer(spotify.NewPlaylistCache(spotifyClient), evaluator, CLI.Stats.Path, all)
+		s.quiet = true
+		stats, err := medleyStats(s, medleys[0], CLI.Stats.Top)
+		handleError(err)
+		handleError(printStats(stats, CLI.Stats.Format))
 	default:
 		panic(ctx.Command())
 	}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mhborthwick/medley/cli/pkg/spotify"
+	"github.com/mhborthwick/medley/cli/pkg/state"
+)
+
+// restorePlaylist puts a playlist back into the state it was in when
+// snapshot was taken, order included. The playlist as it is now is
+// snapshotted first, so a restore can be undone too. Tracks it adds
+// back to a medley destination are recorded as medley's again if they
+// were medley's before the restore or when snapshot was taken, so
+// tracks added by hand are never removed by a later sync.
+func restorePlaylist(spotifyClient spotify.Spotify, stateDir, playlistID string, snapshot state.Snapshot) error {
+	snapshotID, err := spotifyClient.GetSnapshotID(playlistID)
+	if err != nil {
+		return err
+	}
+	items, err := spotifyClient.GetAllItems(playlistID)
+	if err != nil {
+		return err
+	}
+	st, _, err := state.Load(stateDir, playlistID)
+	if err != nil {
+		return err
+	}
+	before, err := state.SaveSnapshot(stateDir, playlistID, snapshotID, items, st.OwnedOf(items), time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Println("Snapshot:", before.ID)
+
+	owned := make(map[string]bool)
+	for _, uri := range snapshot.Owned {
+		owned[uri] = true
+	}
+
+	current := make([]string, len(items))
+	for i, item := range items {
+		current[i] = item.Track.URI
+	}
+	desired := snapshot.URIs()
+	toRemove, toAdd := spotify.PlanRestore(current, desired)
+
+	fmt.Println("removing", len(toRemove), "tracks")
+	fmt.Println("adding", len(toAdd), "tracks")
+
+	// every step is planned in a journal first, so an
+	// interrupted restore can be finished with medley resume
+	journal := state.NewJournal("restore", snapshot.ID, playlistID, time.Now())
+
+	// in batches, because spotify caps how many
+	// songs it takes per request
+	for _, payload := range spotify.Batches(toRemove, spotify.BatchSize) {
+		journal.Ops = append(journal.Ops, state.Op{Kind: state.OpRemove, URIs: payload})
+	}
+	for _, payload := range spotify.Batches(toAdd, spotify.BatchSize) {
+		// tracks removed only to change how often they
+		// occur keep the source they were added from
+		from := make(map[string]string)
+		var mine []string
+		for _, uri := range payload {
+			from[uri] = st.Tracks[uri].Source
+			if st.Owns(uri) || owned[uri] {
+				mine = append(mine, uri)
+			}
+		}
+		journal.Ops = append(journal.Ops, state.Op{Kind: state.OpAdd, URIs: payload, Sources: from, Owned: mine})
+	}
+	// the playlist then holds the same tracks as
+	// the snapshot, so moving them is all that's left
+	journal.Ops = append(journal.Ops, state.Op{Kind: state.OpRestoreOrder, URIs: desired})
+
+	if err := journal.Save(stateDir); err != nil {
+		return err
+	}
+	return runJournal(spotifyClient, stateDir, journal, false)
+}
+
+// restoreOrder moves the tracks of a playlist, which holds the same
+// tracks as desired, into the order of desired.
+func restoreOrder(spotifyClient spotify.Spotify, playlistID string, desired []string) error {
+	snapshotID, err := spotifyClient.GetSnapshotID(playlistID)
+	if err != nil {
+		return err
+	}
+	items, err := spotifyClient.GetAllItems(playlistID)
+	if err != nil {
+		return err
+	}
+	current := make([]string, len(items))
+	for i, item := range items {
+		current[i] = item.Track.URI
+	}
+	return applyMoves(spotifyClient, playlistID, snapshotID, spotify.Reorder(current, desired))
+}
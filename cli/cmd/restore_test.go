@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mhborthwick/medley/cli/pkg/spotify"
+	"github.com/mhborthwick/medley/cli/pkg/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestorePlaylist(t *testing.T) {
+	t.Run("records only tracks medley owned as medley's", func(t *testing.T) {
+		destination := "dddddddddddddddddddddd"
+		hand := spotify.PlaylistItem{AddedBy: spotify.User{ID: "friend"}, Track: spotify.Track{URI: "hand"}}
+		mine := spotify.PlaylistItem{AddedBy: spotify.User{ID: "me"}, Track: spotify.Track{URI: "mine"}}
+		// since the snapshot, sync removed mine and
+		// the track added by hand was added again
+		fake := newFakeSpotify(map[string][]spotify.PlaylistItem{destination: {hand, hand}})
+		defer fake.Close()
+		stateDir := t.TempDir()
+		st, _, err := state.Load(stateDir, destination)
+		assert.Nil(t, err)
+		assert.Nil(t, st.Save(stateDir))
+
+		snapshot := state.Snapshot{ID: "20240701T000000Z", Items: []spotify.PlaylistItem{hand, mine}, Owned: []string{"mine"}}
+		assert.Nil(t, restorePlaylist(fake.client(), stateDir, destination, snapshot))
+		assert.Equal(t, []string{"hand", "mine"}, fake.uris(destination))
+		st, _, err = state.Load(stateDir, destination)
+		assert.Nil(t, err)
+		assert.True(t, st.Owns("mine"))
+		assert.False(t, st.Owns("hand"))
+	})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mhborthwick/medley/cli/pkg/spotify"
+)
+
+// medleyStats evaluates a medley and summarizes it along with the
+// sources it's made of, without changing its destination. Playlists
+// whose tracks are removed from it aren't counted as sources.
+func medleyStats(s syncer, m spotify.Medley, top int) (spotify.Stats, error) {
+	var ids []string
+	sources := make(map[string][]spotify.PlaylistItem)
+	for _, p := range spotify.Contributing(m.Config.GetSource()) {
+		id, err := spotify.GetID(p.ID)
+		if err != nil {
+			return spotify.Stats{}, err
+		}
+		if _, done := sources[id]; done {
+			continue
+		}
+		// evaluated on its own so a window
+		// still limits what the source has
+		items, err := p.Evaluate(s.cache)
+		if err != nil {
+			return spotify.Stats{}, err
+		}
+		ids = append(ids, id)
+		sources[id] = items
+	}
+	items, _, err := s.evaluate(m)
+	if err != nil {
+		return spotify.Stats{}, err
+	}
+	return spotify.ComputeStats(ids, sources, items, top), nil
+}
+
+// printStats writes stats as json or as tables.
+func printStats(stats spotify.Stats, format string) error {
+	if format == "json" {
+		out, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	duration := time.Duration(stats.DurationMs) * time.Millisecond
+	fmt.Println("Tracks:", stats.Tracks)
+	fmt.Println("Duration:", duration.Round(time.Second))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\n#\tSOURCE\tTRACKS\tCONTRIBUTED\tUNIQUE")
+	for i, s := range stats.Sources {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%d\n", i+1, s.ID, s.Tracks, s.Contributed, s.Unique)
+	}
+
+	// shared tracks and jaccard index of each pair,
+	// with sources numbered as in the table above
+	if len(stats.Sources) > 1 {
+		index := make(map[string]int, len(stats.Sources))
+		header := []string{"\nOVERLAP"}
+		for i, s := range stats.Sources {
+			index[s.ID] = i
+			header = append(header, fmt.Sprint(i+1))
+		}
+		matrix := make([][]string, len(stats.Sources))
+		for i := range matrix {
+			matrix[i] = make([]string, len(stats.Sources))
+			for j := range matrix[i] {
+				matrix[i][j] = "-"
+			}
+		}
+		for _, o := range stats.Overlaps {
+			cell := fmt.Sprintf("%d (%.2f)", o.Shared, o.Jaccard)
+			matrix[index[o.A]][index[o.B]] = cell
+			matrix[index[o.B]][index[o.A]] = cell
+		}
+		fmt.Fprintln(w, strings.Join(header, "\t"))
+		for i, row := range matrix {
+			fmt.Fprintf(w, "%d\t%s\n", i+1, strings.Join(row, "\t"))
+		}
+	}
+
+	fmt.Fprintln(w, "\nARTIST\tTRACKS")
+	for _, a := range stats.TopArtists {
+		fmt.Fprintf(w, "%s\t%d\n", a.Name, a.Tracks)
+	}
+
+	// decades in order, with unknown
+	// release dates sorted last
+	decades := make([]string, 0, len(stats.Decades))
+	for d := range stats.Decades {
+		decades = append(decades, d)
+	}
+	slices.Sort(decades)
+	fmt.Fprintln(w, "\nDECADE\tTRACKS")
+	for _, d := range decades {
+		fmt.Fprintf(w, "%s\t%d\n", d, stats.Decades[d])
+	}
+	return w.Flush()
+}
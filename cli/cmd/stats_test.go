@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mhborthwick/medley/cli/pkg/spotify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMedleyStats(t *testing.T) {
+	from := "ffffffffffffffffffffff"
+	remove := "rrrrrrrrrrrrrrrrrrrrrr"
+	// both playlists have the same two tracks
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if !strings.Contains(r.URL.Path, from) && !strings.Contains(r.URL.Path, remove) {
+			w.Write([]byte(`{"items": [], "next": null}`))
+			return
+		}
+		w.Write([]byte(`{"items": [{"track": {"uri": "a"}}, {"track": {"uri": "b"}}], "next": null}`))
+	}))
+	defer mockServer.Close()
+
+	t.Run("doesn't count removed playlists as sources", func(t *testing.T) {
+		cache := spotify.NewPlaylistCache(spotify.Spotify{URL: mockServer.URL, Token: "token", Client: &http.Client{}})
+		m := spotify.Medley{Name: "a", Config: spotify.SyncConfig{MedleyConfig: &spotify.MedleyConfig{Source: spotify.ExceptSource{
+			From:   spotify.PlaylistSource{ID: from},
+			Remove: []spotify.Source{spotify.PlaylistSource{ID: remove}},
+		}}}}
+		s := newSyncer(cache, nil, "medleys.pkl", []spotify.Medley{m})
+		s.quiet = true
+		stats, err := medleyStats(s, m, 10)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, stats.Tracks)
+		assert.Len(t, stats.Sources, 1)
+		assert.Equal(t, from, stats.Sources[0].ID)
+	})
+}
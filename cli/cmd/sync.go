@@ -0,0 +1,575 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/mhborthwick/medley/cli/pkg/spotify"
+	"github.com/mhborthwick/medley/cli/pkg/state"
+)
+
+// syncer syncs the medleys declared in a config file.
+type syncer struct {
+	// cache is shared by every medley synced in a run,
+	// so medleys sharing a source only fetch it once
+	cache           *spotify.PlaylistCache
+	evaluator       pkl.Evaluator
+	path            string
+	allowMassDelete bool
+	// medleys are all the medleys declared in the file, by name,
+	// so the medleys a medley reads can be evaluated for it
+	medleys map[string]spotify.Medley
+	// quiet keeps evaluate from printing what it filtered
+	quiet bool
+}
+
+// newSyncer returns a syncer of the medleys of a config file.
+func newSyncer(cache *spotify.PlaylistCache, evaluator pkl.Evaluator, path string, medleys []spotify.Medley) syncer {
+	byName := make(map[string]spotify.Medley, len(medleys))
+	for _, m := range medleys {
+		byName[m.Name] = m
+	}
+	return syncer{cache: cache, evaluator: evaluator, path: path, medleys: byName}
+}
+
+// syncMedley syncs the destination of a single medley with its sources.
+func (s syncer) syncMedley(m spotify.Medley) error {
+	cfg := m.Config
+	cache := s.cache
+	spotifyClient := cache.Spotify
+
+	id, err := spotify.GetID(cfg.Destination)
+	if err != nil {
+		return err
+	}
+	// medleys synced after this one and reading
+	// its destination as a playlist have to fetch it again
+	defer cache.Forget(id)
+
+	stateDir, err := state.Dir()
+	if err != nil {
+		return err
+	}
+
+	// an earlier sync or restore of the destination that
+	// was interrupted is finished before syncing it again
+	_, err = resumeJournals(spotifyClient, stateDir, func(j *state.Journal) bool {
+		return j.Destination == id
+	})
+	if err != nil {
+		return err
+	}
+
+	// get all items from target playlist
+	target, err := spotifyClient.GetAllItems(id)
+	if err != nil {
+		return err
+	}
+
+	st, exists, err := state.Load(stateDir, id)
+	if err != nil {
+		return err
+	}
+
+	// create target map
+	// tracks added by someone else are never removed
+	targetMap := make(map[string]bool)
+	manual := make(map[string]bool)
+	for _, t := range target {
+		targetMap[t.Track.URI] = false
+		if t.AddedBy.ID != "" && t.AddedBy.ID != cfg.UserID {
+			manual[t.Track.URI] = true
+		}
+	}
+
+	// the first sync with no state adopts the destination
+	// as it is, so existing medleys keep syncing as before
+	if !exists {
+		for _, t := range target {
+			if !manual[t.Track.URI] {
+				st.Add(t.Track.URI, "", time.Now())
+			}
+		}
+	}
+
+	// pinned tracks are always added, so they never expire
+	pinned := make(map[string]bool)
+	for _, p := range cfg.Pinned {
+		uri, err := p.URI()
+		if err != nil {
+			return err
+		}
+		pinned[uri] = true
+	}
+
+	// tracks medley added longer ago than expire.after
+	// are removed, even if they're still in a source
+	var owned []spotify.PlaylistItem
+	for _, t := range target {
+		if st.Owns(t.Track.URI) && !manual[t.Track.URI] && !pinned[t.Track.URI] {
+			owned = append(owned, t)
+		}
+	}
+	expiring := cfg.Expire.Expired(owned, time.Now())
+
+	source := cfg.GetSource()
+	// playlists only removed from the source can be
+	// empty without anything looking removed
+	contributing := make(map[string]bool)
+	for _, p := range spotify.Contributing(source) {
+		contributing[p.ID] = true
+	}
+
+	// sources with no tracks, which would otherwise
+	// look like every one of their tracks was removed
+	var emptySources []string
+	// last seen version of each source, replaces
+	// st.Sources so dropped sources are forgotten
+	sources := make(map[string]state.Source)
+
+	for _, p := range source.Playlists() {
+		id, err := spotify.GetID(p.ID)
+		if err != nil {
+			return err
+		}
+		if _, done := sources[id]; done {
+			continue
+		}
+		snapshotID, err := cache.GetSnapshotID(id)
+		if err != nil {
+			return err
+		}
+		// sources that haven't changed since the last sync
+		// are read from state instead of paginated
+		market := cache.Spotify.Market
+		if src := st.Sources[id]; src.Reusable(snapshotID, market) && !cache.Has(id) {
+			cache.Put(id, src.Items)
+		}
+		// windowed sources only fetch their recent tracks when
+		// evaluated, unless the whole playlist is already at hand
+		if p.Windowed() && !cache.Has(id) {
+			continue
+		}
+		items, err := cache.GetAllItems(id)
+		if err != nil {
+			return err
+		}
+		sources[id] = state.Source{SnapshotID: snapshotID, Items: items, Market: market}
+		if len(items) == 0 && contributing[p.ID] {
+			emptySources = append(emptySources, p.ID)
+		}
+	}
+
+	// get all uris from the evaluated source,
+	// in the order the medley should have them
+	items, exclusion, err := s.evaluate(m)
+	if err != nil {
+		return err
+	}
+	// expired tracks stay out for as long as they're in
+	// the medley, and may come back once they've left it
+	st.Expire(expiring, time.Now())
+	inMedley := make(map[string]bool)
+	for _, item := range items {
+		inMedley[item.Track.URI] = true
+	}
+	for uri := range st.Expired {
+		if !inMedley[uri] || pinned[uri] {
+			delete(st.Expired, uri)
+		}
+	}
+	var all []string
+	// the source each uri was found in
+	sourceOf := make(map[string]string)
+	for _, item := range items {
+		if st.IsExpired(item.Track.URI) {
+			continue
+		}
+		all = append(all, item.Track.URI)
+		sourceOf[item.Track.URI] = item.Source
+	}
+
+	// if uri in target playlist
+	// set value to true
+	// if not, add to toAdd slice
+	toAdd := []string{}
+
+	for _, a := range all {
+		_, ok := targetMap[a]
+		if ok {
+			targetMap[a] = true
+		} else {
+			toAdd = append(toAdd, a)
+		}
+	}
+
+	// cleans duplicate "toAdd songs"
+	// we won't need to clean "toRemove songs"
+	// because the way we evaluate them
+	uniqueToAddMap := make(map[string]bool)
+	uniqueToAdd := make([]string, 0, len(uniqueToAddMap))
+	for _, uri := range toAdd {
+		if _, found := uniqueToAddMap[uri]; !found {
+			uniqueToAddMap[uri] = true
+			uniqueToAdd = append(uniqueToAdd, uri)
+		}
+	}
+
+	// creates multiple payloads to send in batches
+	// because spotify caps how many songs it takes per request
+	toAddPayloads := spotify.Batches(uniqueToAdd, spotify.BatchSize)
+
+	fmt.Println("adding", toAddPayloads)
+
+	// get values still set to false
+	// these should be deleted, but only if medley
+	// added them, anything added by hand is kept
+	toRemove := []string{}
+
+	for k, v := range targetMap {
+		if !v && st.Owns(k) && !manual[k] {
+			toRemove = append(toRemove, k)
+		}
+	}
+
+	// excluded tracks are removed wherever they came from
+	excluding := []string{}
+	excluded := make(map[string]bool)
+	deliberate := make(map[string]bool)
+	for _, uri := range expiring {
+		deliberate[uri] = true
+	}
+	for _, t := range target {
+		uri := t.Track.URI
+		if exclusion.Rule(t.Track) == "" || excluded[uri] {
+			continue
+		}
+		excluding = append(excluding, uri)
+		excluded[uri] = true
+		if !st.Owns(uri) || manual[uri] {
+			toRemove = append(toRemove, uri)
+		}
+		deliberate[uri] = true
+	}
+
+	fmt.Println("excluding", excluding)
+
+	// expired and excluded tracks are removed on
+	// purpose, so they don't count towards the guard
+	if !s.allowMassDelete {
+		if err := spotify.CheckDeletions(cfg, len(toRemove)-len(deliberate), len(targetMap), emptySources); err != nil {
+			return err
+		}
+	}
+
+	// every change is planned in a journal first,
+	// so an interrupted sync can be finished with medley resume
+	journal := state.NewJournal("sync", s.path, id, time.Now())
+
+	// removed tracks are archived before they're removed, expired
+	// tracks in their own archive if they have one. Excluded tracks
+	// didn't age or rotate out, so they aren't archived at all
+	var expired []string
+	for _, uri := range expiring {
+		if !excluded[uri] {
+			expired = append(expired, uri)
+		}
+	}
+	separately := cfg.Expire.Archive != "" && len(expired) > 0
+	if separately {
+		expiryArchive, err := spotify.GetID(cfg.Expire.Archive)
+		if err != nil {
+			return err
+		}
+		journal.Ops = append(journal.Ops, state.Op{Kind: state.OpArchive, Playlist: expiryArchive, URIs: expired})
+	}
+	var toArchive []string
+	for _, uri := range toRemove {
+		if !excluded[uri] && !(separately && slices.Contains(expired, uri)) {
+			toArchive = append(toArchive, uri)
+		}
+	}
+	if len(toArchive) > 0 {
+		archive, err := archivePlaylist(spotifyClient, cfg.Archive, st, stateDir, time.Now())
+		if err != nil {
+			return err
+		}
+		if archive != "" {
+			journal.Ops = append(journal.Ops, state.Op{Kind: state.OpArchive, Playlist: archive, URIs: toArchive})
+		}
+	}
+
+	// creates multiple payloads to send in batches
+	// because spotify caps how many songs it takes per request
+	toRemovePayloads := spotify.Batches(toRemove, spotify.BatchSize)
+
+	fmt.Println("removing", toRemovePayloads)
+
+	// handle deletion
+	for _, p := range toRemovePayloads {
+		journal.Ops = append(journal.Ops, state.Op{Kind: state.OpRemove, URIs: p})
+	}
+
+	// reverse items in toAddPayloads
+	slices.Reverse(toAddPayloads)
+
+	// handle addition
+	for _, p := range toAddPayloads {
+		from := make(map[string]string)
+		for _, uri := range p {
+			from[uri] = sourceOf[uri]
+		}
+		journal.Ops = append(journal.Ops, state.Op{Kind: state.OpAdd, URIs: p, Sources: from, Owned: p, Prepend: true})
+	}
+
+	// a shuffle or sort is applied by moving tracks around,
+	// so tracks that stay aren't deleted and re-added
+	// and pinned tracks are kept in place the same way
+	if cfg.Ordered() {
+		journal.Ops = append(journal.Ops, state.Op{Kind: state.OpReorder, URIs: all, Pins: cfg.Pinned})
+	} else if len(cfg.Pinned) > 0 {
+		journal.Ops = append(journal.Ops, state.Op{Kind: state.OpReorder, Pins: cfg.Pinned})
+	}
+
+	// save the destination as it is before changing it,
+	// so it can be put back with medley restore
+	if len(journal.Ops) > 0 {
+		snapshotID, err := spotifyClient.GetSnapshotID(id)
+		if err != nil {
+			return err
+		}
+		snapshot, err := state.SaveSnapshot(stateDir, id, snapshotID, target, st.OwnedOf(target), time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Println("Snapshot:", snapshot.ID)
+	}
+
+	// the journal reads state from disk,
+	// so what's been learned so far is saved first
+	st.Sources = sources
+	if err := st.Save(stateDir); err != nil {
+		return err
+	}
+	if err := journal.Save(stateDir); err != nil {
+		return err
+	}
+	if err := runJournal(spotifyClient, stateDir, journal, false); err != nil {
+		return err
+	}
+	return nil
+}
+
+// market returns the market to fetch playlists for, if any of the
+// medleys needs one. Medleys synced in a run share their fetches.
+func market(medleys []spotify.Medley) string {
+	for _, m := range medleys {
+		if market := m.Config.Dedupe.Market(); market != "" {
+			return market
+		}
+	}
+	return ""
+}
+
+// evaluate returns the tracks of a medley in the order it should have
+// them, along with what it excludes. The medleys it reads are evaluated
+// first, unless they already were in this run.
+func (s syncer) evaluate(m spotify.Medley) ([]spotify.PlaylistItem, spotify.Exclusion, error) {
+	cfg := m.Config
+	for _, name := range m.Reads {
+		if _, ok := s.cache.GetMedley(name); ok {
+			continue
+		}
+		read, ok := s.medleys[name]
+		if !ok {
+			return nil, spotify.Exclusion{}, fmt.Errorf("medley %s reads medley %s, which isn't declared", m.Name, name)
+		}
+		// only the medley asked for reports what it filtered
+		quiet := s
+		quiet.quiet = true
+		if _, _, err := quiet.evaluate(read); err != nil {
+			return nil, spotify.Exclusion{}, err
+		}
+	}
+	items, err := cfg.GetSource().Evaluate(s.cache)
+	if err != nil {
+		return nil, spotify.Exclusion{}, err
+	}
+	exclusion, err := cfg.Exclude.Load(s.cache)
+	if err != nil {
+		return nil, spotify.Exclusion{}, err
+	}
+	items, results := exclusion.Apply(items)
+	s.report(results)
+	groups, err := cfg.Dedupe.Group(items)
+	if err != nil {
+		return nil, spotify.Exclusion{}, err
+	}
+	items, results, err = cfg.Dedupe.Pick(groups)
+	if err != nil {
+		return nil, spotify.Exclusion{}, err
+	}
+	if !s.quiet {
+		printMergedGroups(groups, items)
+	}
+	s.report(results)
+	items, results = cfg.Filter.Apply(items)
+	s.report(results)
+	predicate := spotify.Predicate{Evaluator: s.evaluator, Path: s.path, Member: m.Member}
+	items, results, err = predicate.Apply(context.Background(), items)
+	if err != nil {
+		return nil, spotify.Exclusion{}, err
+	}
+	s.report(results)
+	items, results, err = cfg.Limit.Apply(items)
+	if err != nil {
+		return nil, spotify.Exclusion{}, err
+	}
+	s.report(results)
+	items, err = cfg.Order(items, time.Now())
+	if err != nil {
+		return nil, spotify.Exclusion{}, err
+	}
+	items, err = spotify.WithPinned(items, cfg.Pinned, exclusion, s.cache)
+	if err != nil {
+		return nil, spotify.Exclusion{}, err
+	}
+	s.cache.PutMedley(m.Name, items)
+	return items, exclusion, nil
+}
+
+// report prints how many tracks each filter rule or limit removed,
+// unless the syncer is quiet.
+func (s syncer) report(results []spotify.FilterResult) {
+	if !s.quiet {
+		printFilterResults(results)
+	}
+}
+
+// archivePlaylist returns the id of the playlist removed tracks are
+// archived to, creating this month's archive if it doesn't exist yet.
+// It returns "" if the medley has no archive.
+func archivePlaylist(spotifyClient spotify.Spotify, archive spotify.Archive, st *state.State, stateDir string, now time.Time) (string, error) {
+	if archive.Monthly == "" {
+		if archive.Playlist == "" {
+			return "", nil
+		}
+		return spotify.GetID(archive.Playlist)
+	}
+	name := archive.Name(now)
+	if id, ok := st.Archives[name]; ok {
+		return id, nil
+	}
+	id, err := spotifyClient.CreateNamedPlaylist(name)
+	if err != nil {
+		return "", err
+	}
+	fmt.Println("Archive:", "https://open.spotify.com/playlist/"+id)
+	// saved right away so a failed sync
+	// doesn't create it again when rerun
+	st.Archives[name] = id
+	if err := st.Save(stateDir); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// archiveTracks appends uris to the archive playlist. Tracks already
+// in it are skipped, so a rerun doesn't archive them twice.
+func archiveTracks(spotifyClient spotify.Spotify, id string, uris []string) error {
+	existing, err := spotifyClient.GetAllItems(id)
+	if err != nil {
+		return err
+	}
+	archived := make(map[string]bool)
+	for _, item := range existing {
+		archived[item.Track.URI] = true
+	}
+	var toArchive []string
+	for _, uri := range uris {
+		if !archived[uri] {
+			archived[uri] = true
+			toArchive = append(toArchive, uri)
+		}
+	}
+
+	fmt.Println("archiving", len(toArchive), "tracks")
+
+	// in batches, like additions
+	for _, payload := range spotify.Batches(toArchive, spotify.BatchSize) {
+		if _, err := spotifyClient.AddItemsToPlaylist(payload, id, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reorderPlaylist moves the tracks of a playlist into order. Tracks that
+// aren't in order, e.g. tracks added by hand, keep their positions. Pinned
+// tracks are then moved to their positions. Without an order, only the
+// pinned tracks are moved.
+func reorderPlaylist(spotifyClient spotify.Spotify, playlistID string, order []string, pins []spotify.Pin) error {
+	snapshotID, err := spotifyClient.GetSnapshotID(playlistID)
+	if err != nil {
+		return err
+	}
+	items, err := spotifyClient.GetAllItems(playlistID)
+	if err != nil {
+		return err
+	}
+	current := make([]string, len(items))
+	for i, item := range items {
+		current[i] = item.Track.URI
+	}
+	arranged := current
+	if order != nil {
+		arranged = spotify.Arrange(current, order)
+	}
+	arranged, err = spotify.PinTracks(arranged, pins)
+	if err != nil {
+		return err
+	}
+	moves := spotify.Reorder(current, arranged)
+	return applyMoves(spotifyClient, playlistID, snapshotID, moves)
+}
+
+// applyMoves moves ranges of tracks of a playlist, one after the other,
+// starting from the playlist at snapshotID.
+func applyMoves(spotifyClient spotify.Spotify, playlistID, snapshotID string, moves []spotify.Move) error {
+	fmt.Println("reordering", len(moves), "ranges")
+
+	var err error
+	for _, m := range moves {
+		snapshotID, err = spotifyClient.ReorderPlaylistItems(playlistID, m, snapshotID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printFilterResults reports how many tracks each filter rule or limit removed.
+func printFilterResults(results []spotify.FilterResult) {
+	for _, r := range results {
+		fmt.Println("filter", r.Rule, "removed", r.Removed, "tracks")
+	}
+}
+
+// printMergedGroups reports the versions of tracks that dedupe merged
+// into one, and which version was kept.
+func printMergedGroups(groups [][]spotify.PlaylistItem, kept []spotify.PlaylistItem) {
+	for i, versions := range groups {
+		if len(versions) < 2 {
+			continue
+		}
+		fmt.Println("merged", len(versions), "versions, keeping", kept[i].Track)
+		for _, v := range versions {
+			if v.Track.URI != kept[i].Track.URI {
+				fmt.Println("  dropping", v.Track, v.Track.URI)
+			}
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/mhborthwick/medley/cli/pkg/spotify"
+	"github.com/mhborthwick/medley/cli/pkg/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// noWhere evaluates configs as if they declare no where predicate.
+type noWhere struct {
+	pkl.Evaluator
+}
+
+func (noWhere) EvaluateExpression(ctx context.Context, source *pkl.ModuleSource, expr string, out interface{}) error {
+	return nil
+}
+
+func TestSyncMedley(t *testing.T) {
+	destination := "dddddddddddddddddddddd"
+	source := "ssssssssssssssssssssss"
+	track := func(id string, addedAt time.Time) spotify.PlaylistItem {
+		return spotify.PlaylistItem{
+			AddedAt: addedAt.UTC().Format(time.RFC3339),
+			AddedBy: spotify.User{ID: "me"},
+			Track:   spotify.Track{URI: "spotify:track:" + id},
+		}
+	}
+	monthAgo := time.Now().AddDate(0, -1, 0)
+	pin := "pppppppppppppppppppppp"
+	old := "oooooooooooooooooooooo"
+	fresh := "ffffffffffffffffffffff"
+
+	t.Run("keeps pinned tracks that are older than expire.after", func(t *testing.T) {
+		stateDir := t.TempDir()
+		t.Setenv("MEDLEY_STATE_DIR", stateDir)
+		fake := newFakeSpotify(map[string][]spotify.PlaylistItem{
+			destination: {track(pin, monthAgo), track(old, monthAgo)},
+			source:      {track(old, monthAgo), track(fresh, monthAgo)},
+		})
+		defer fake.Close()
+		st, _, err := state.Load(stateDir, destination)
+		assert.Nil(t, err)
+		st.Add("spotify:track:"+pin, "", monthAgo)
+		st.Add("spotify:track:"+old, source, monthAgo)
+		assert.Nil(t, st.Save(stateDir))
+
+		m := spotify.Medley{Name: "a", Config: spotify.SyncConfig{
+			MedleyConfig: &spotify.MedleyConfig{
+				UserID:    "me",
+				Playlists: []string{source},
+				Pinned:    []spotify.Pin{{Track: pin, Position: 1}},
+			},
+			Destination: destination,
+			Expire:      spotify.Expire{After: &pkl.Duration{Value: 7, Unit: pkl.Day}},
+		}}
+		s := newSyncer(spotify.NewPlaylistCache(fake.client()), noWhere{}, "medleys.pkl", []spotify.Medley{m})
+		s.quiet = true
+		assert.Nil(t, s.syncMedley(m))
+
+		assert.Equal(t, []string{"spotify:track:" + pin, "spotify:track:" + fresh}, fake.uris(destination))
+		st, _, err = state.Load(stateDir, destination)
+		assert.Nil(t, err)
+		assert.False(t, st.IsExpired("spotify:track:"+pin))
+		assert.True(t, st.IsExpired("spotify:track:"+old))
+	})
+
+	t.Run("archives tracks that rotated out but not excluded ones", func(t *testing.T) {
+		stateDir := t.TempDir()
+		t.Setenv("MEDLEY_STATE_DIR", stateDir)
+		archive := "aaaaaaaaaaaaaaaaaaaaaa"
+		banned := "bbbbbbbbbbbbbbbbbbbbbb"
+		fake := newFakeSpotify(map[string][]spotify.PlaylistItem{
+			destination: {track(old, monthAgo), track(banned, monthAgo)},
+			source:      {track(banned, monthAgo), track(fresh, monthAgo)},
+			archive:     nil,
+		})
+		defer fake.Close()
+		st, _, err := state.Load(stateDir, destination)
+		assert.Nil(t, err)
+		st.Add("spotify:track:"+old, source, monthAgo)
+		st.Add("spotify:track:"+banned, source, monthAgo)
+		assert.Nil(t, st.Save(stateDir))
+
+		m := spotify.Medley{Name: "a", Config: spotify.SyncConfig{
+			MedleyConfig: &spotify.MedleyConfig{
+				UserID:    "me",
+				Playlists: []string{source},
+				Exclude:   spotify.Exclude{Tracks: []string{"spotify:track:" + banned}},
+			},
+			Destination: destination,
+			Archive:     spotify.Archive{Playlist: archive},
+		}}
+		s := newSyncer(spotify.NewPlaylistCache(fake.client()), noWhere{}, "medleys.pkl", []spotify.Medley{m})
+		s.quiet = true
+		assert.Nil(t, s.syncMedley(m))
+
+		assert.Equal(t, []string{"spotify:track:" + fresh}, fake.uris(destination))
+		assert.Equal(t, []string{"spotify:track:" + old}, fake.uris(archive))
+	})
+
+	t.Run("syncs a destination in batches and resumes a failed sync", func(t *testing.T) {
+		stateDir := t.TempDir()
+		t.Setenv("MEDLEY_STATE_DIR", stateDir)
+		hand := spotify.PlaylistItem{AddedBy: spotify.User{ID: "friend"}, Track: spotify.Track{URI: "spotify:track:hand"}}
+		var sourceItems []spotify.PlaylistItem
+		var want []string
+		for i := range 150 {
+			id := fmt.Sprintf("%022d", i)
+			sourceItems = append(sourceItems, track(id, monthAgo))
+			want = append(want, "spotify:track:"+id)
+		}
+		fake := newFakeSpotify(map[string][]spotify.PlaylistItem{
+			destination: {hand, track(old, monthAgo)},
+			source:      sourceItems,
+		})
+		defer fake.Close()
+		st, _, err := state.Load(stateDir, destination)
+		assert.Nil(t, err)
+		st.Add("spotify:track:"+old, source, monthAgo)
+		assert.Nil(t, st.Save(stateDir))
+		// the second batch of additions fails
+		adds := 0
+		fake.fail = func(r *http.Request) bool {
+			if r.Method == "POST" {
+				adds++
+			}
+			return r.Method == "POST" && adds == 2
+		}
+
+		m := spotify.Medley{Name: "a", Config: spotify.SyncConfig{
+			MedleyConfig: &spotify.MedleyConfig{UserID: "me", Playlists: []string{source}},
+			Destination:  destination,
+		}}
+		s := newSyncer(spotify.NewPlaylistCache(fake.client()), noWhere{}, "medleys.pkl", []spotify.Medley{m})
+		s.quiet = true
+		assert.NotNil(t, s.syncMedley(m))
+		assert.Len(t, fake.uris(destination), 51)
+		pending, err := state.Pending(stateDir)
+		assert.Nil(t, err)
+		assert.Len(t, pending, 1)
+
+		_, err = resumeJournals(fake.client(), stateDir, func(j *state.Journal) bool { return true })
+		assert.Nil(t, err)
+		assert.Equal(t, append(want, hand.Track.URI), fake.uris(destination))
+		st, _, err = state.Load(stateDir, destination)
+		assert.Nil(t, err)
+		assert.True(t, st.Owns(want[0]))
+		assert.True(t, st.Owns(want[149]))
+		assert.False(t, st.Owns("spotify:track:"+old))
+		assert.False(t, st.Owns(hand.Track.URI))
+		snapshots, err := state.Snapshots(stateDir, destination)
+		assert.Nil(t, err)
+		assert.Len(t, snapshots, 1)
+	})
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/mhborthwick/medley/cli/pkg/spotify"
+	"github.com/mhborthwick/medley/cli/pkg/state"
+)
+
+// pollInterval is how often watch checks the config file for changes.
+const pollInterval = 5 * time.Second
+
+// watcher syncs the medleys of a config file on their schedules,
+// reloading the file whenever it changes.
+type watcher struct {
+	path            string
+	allowMassDelete bool
+	// modTimes are when the config file and every module it
+	// imports were changed as last loaded, by path
+	modTimes  map[string]time.Time
+	evaluator pkl.Evaluator
+	userID    string
+	// medleys are the medleys with a schedule, all
+	// every medley of the file, which they may read
+	medleys []spotify.Medley
+	all     []spotify.Medley
+	// next is when each medley is synced next, by name
+	next map[string]time.Time
+	// failures counts the syncs of each medley that failed in a row
+	failures map[string]int
+	// force is set for medleys whose config changed,
+	// which are synced even if no source did
+	force map[string]bool
+}
+
+func newWatcher(path string, allowMassDelete bool) *watcher {
+	return &watcher{
+		path:            path,
+		allowMassDelete: allowMassDelete,
+		next:            make(map[string]time.Time),
+		failures:        make(map[string]int),
+		force:           make(map[string]bool),
+	}
+}
+
+// run syncs medleys as they come due, until it fails to load the config
+// the first time. Later config errors keep the medleys loaded before.
+func (w *watcher) run() error {
+	defer func() {
+		if w.evaluator != nil {
+			w.evaluator.Close()
+		}
+	}()
+	for {
+		if err := w.reload(time.Now()); err != nil {
+			if w.medleys == nil {
+				return err
+			}
+			fmt.Println(err.Error())
+		}
+		w.syncDue(time.Now())
+		time.Sleep(w.wait(time.Now()))
+	}
+}
+
+// reload evaluates the config file again if it or a module
+// it imports changed since last loaded.
+func (w *watcher) reload(now time.Time) error {
+	modTimes, err := moduleModTimes(w.path)
+	if err != nil {
+		return err
+	}
+	if maps.Equal(modTimes, w.modTimes) {
+		return nil
+	}
+	// a broken config is only reported once, until it changes again
+	w.modTimes = modTimes
+	fmt.Println("Evaluating from: " + w.path)
+
+	// a new evaluator, so the changed file isn't read from its cache
+	evaluator, err := pkl.NewEvaluator(context.Background(), pkl.PreconfiguredOptions)
+	if err != nil {
+		return err
+	}
+	var cfg spotify.SyncConfig
+	if err := evaluator.EvaluateModule(context.Background(), pkl.FileSource(w.path), &cfg); err != nil {
+		evaluator.Close()
+		return err
+	}
+	all, err := spotify.SelectMedleys(cfg, "", len(cfg.Medleys) > 0)
+	if err != nil {
+		evaluator.Close()
+		return err
+	}
+	if err := w.load(all, now); err != nil {
+		evaluator.Close()
+		return err
+	}
+
+	if w.evaluator != nil {
+		w.evaluator.Close()
+	}
+	w.evaluator = evaluator
+	w.userID = cfg.UserID
+	return nil
+}
+
+// importPattern matches the uri of an amends, extends or import clause
+// and of an import expression.
+var importPattern = regexp.MustCompile(`\b(?:amends|extends|import\*?)\s*\(?\s*"([^"]+)"`)
+
+// moduleModTimes returns when the module at path and every local file
+// it imports, directly or through other modules, were last changed.
+// Imports that don't exist yet have a zero time, so creating them counts
+// as a change. Globbed imports and those of packages aren't followed.
+func moduleModTimes(path string) (map[string]time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	modTimes := map[string]time.Time{path: info.ModTime()}
+	queue := []string{path}
+	for len(queue) > 0 {
+		module := queue[0]
+		queue = queue[1:]
+		text, err := os.ReadFile(module)
+		if err != nil {
+			// changed between stat and read, or a
+			// missing import, either way seen next time
+			continue
+		}
+		for _, match := range importPattern.FindAllStringSubmatch(string(text), -1) {
+			uri := match[1]
+			if after, ok := strings.CutPrefix(uri, "file://"); ok {
+				uri = after
+			} else if strings.Contains(uri, ":") || strings.Contains(uri, "*") {
+				continue
+			}
+			if !filepath.IsAbs(uri) {
+				uri = filepath.Join(filepath.Dir(module), uri)
+			}
+			if _, ok := modTimes[uri]; ok {
+				continue
+			}
+			modTimes[uri] = time.Time{}
+			if info, err := os.Stat(uri); err == nil {
+				modTimes[uri] = info.ModTime()
+			}
+			queue = append(queue, uri)
+		}
+	}
+	return modTimes, nil
+}
+
+// load replaces the medleys watched with those of all that have a
+// schedule. Medleys keep their place in the schedule, new ones and those
+// whose config changed, or the config of a medley they read, are synced
+// right away whether or not their sources changed. A change to only a
+// where predicate can't be told apart, so it waits for a source to change.
+func (w *watcher) load(all []spotify.Medley, now time.Time) error {
+	var medleys []spotify.Medley
+	for _, m := range all {
+		if m.Config.Schedule == nil {
+			fmt.Println("not watching", m.Name+", it has no schedule")
+			continue
+		}
+		if _, err := m.Config.Schedule.Next(now); err != nil {
+			return fmt.Errorf("medley %s: %w", m.Name, err)
+		}
+		medleys = append(medleys, m)
+	}
+	if len(medleys) == 0 {
+		return fmt.Errorf("no medley in %s has a schedule", w.path)
+	}
+
+	previous := make(map[string]spotify.Medley, len(w.all))
+	for _, m := range w.all {
+		previous[m.Name] = m
+	}
+	// medleys come after those they read,
+	// which are therefore compared first
+	changed := make(map[string]bool, len(all))
+	for _, m := range all {
+		before, ok := previous[m.Name]
+		changed[m.Name] = !ok || !reflect.DeepEqual(before.Config, m.Config) ||
+			slices.ContainsFunc(m.Reads, func(name string) bool { return changed[name] })
+	}
+
+	w.medleys = medleys
+	w.all = all
+	for _, m := range medleys {
+		if changed[m.Name] {
+			w.force[m.Name] = true
+			w.next[m.Name] = now
+		}
+	}
+	return nil
+}
+
+// syncDue syncs the medleys that are due. Failed syncs are retried
+// after a backoff that grows with every failure in a row, and medleys
+// reading one that failed are retried along with it.
+func (w *watcher) syncDue(now time.Time) {
+	var due []spotify.Medley
+	for _, m := range w.medleys {
+		if !w.next[m.Name].After(now) {
+			due = append(due, m)
+		}
+	}
+	if len(due) == 0 {
+		return
+	}
+
+	spotifyClient, err := newClient(w.userID, market(w.all))
+	if err != nil {
+		// backed off like a failed sync, so an auth
+		// server that's down isn't asked every poll
+		fmt.Println(err.Error())
+		for _, m := range due {
+			w.retry(m, time.Now())
+		}
+		return
+	}
+	// a new cache every round, so snapshot ids are fresh
+	cache := spotify.NewPlaylistCache(spotifyClient)
+	s := newSyncer(cache, w.evaluator, w.path, w.all)
+	s.allowMassDelete = w.allowMassDelete
+
+	failed := make(map[string]bool)
+	for _, m := range due {
+		if name := failedRead(m, s.medleys, failed); name != "" {
+			fmt.Println("skipped", m.Name+", medley", name, "failed to sync")
+			failed[m.Name] = true
+			w.retry(m, time.Now())
+			continue
+		}
+		if err := w.syncIfChanged(s, m); err != nil {
+			fmt.Println(m.Name+":", err.Error())
+			failed[m.Name] = true
+			w.retry(m, time.Now())
+			continue
+		}
+		w.failures[m.Name] = 0
+		delete(w.force, m.Name)
+		// the schedule was checked when loaded
+		w.next[m.Name], _ = m.Config.Schedule.Next(time.Now())
+		fmt.Println("next sync of", m.Name, "at", w.next[m.Name].Format(time.RFC3339))
+	}
+}
+
+// retry schedules another sync of a medley whose sync failed.
+func (w *watcher) retry(m spotify.Medley, now time.Time) {
+	w.failures[m.Name]++
+	w.next[m.Name] = now.Add(spotify.Backoff(w.failures[m.Name]))
+	fmt.Println("retrying", m.Name, "at", w.next[m.Name].Format(time.RFC3339))
+}
+
+// failedRead returns the name of a failed medley that m reads, directly
+// or through the medleys it reads, or "" if there is none.
+func failedRead(m spotify.Medley, medleys map[string]spotify.Medley, failed map[string]bool) string {
+	for _, name := range m.Reads {
+		if failed[name] {
+			return name
+		}
+		if read := failedRead(medleys[name], medleys, failed); read != "" {
+			return read
+		}
+	}
+	return ""
+}
+
+// syncIfChanged syncs a medley unless none of its sources changed
+// since it was last synced.
+func (w *watcher) syncIfChanged(s syncer, m spotify.Medley) error {
+	if !w.force[m.Name] && !changesWithTime(m, s.medleys) {
+		changed, err := sourcesChanged(s.cache, m.Config)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			fmt.Println("Unchanged:", m.Name)
+			return nil
+		}
+	}
+	fmt.Println("Syncing:", m.Name)
+	if err := s.syncMedley(m); err != nil {
+		return err
+	}
+	fmt.Println("Playlist:", "https://open.spotify.com/playlist/"+m.Config.Destination)
+	return nil
+}
+
+// changesWithTime reports whether a medley can change without any of
+// its sources changing, e.g. by shuffling or expiring tracks, or reads
+// a medley that can.
+func changesWithTime(m spotify.Medley, medleys map[string]spotify.Medley) bool {
+	cfg := m.Config
+	if cfg.Shuffle || cfg.Expire.After != nil || len(cfg.Exclude.Playlists) > 0 {
+		return true
+	}
+	return slices.ContainsFunc(m.Reads, func(name string) bool {
+		return changesWithTime(medleys[name], medleys)
+	})
+}
+
+// sourcesChanged reports whether any source of a medley has a different
+// snapshot id than at the last sync. Windowed sources, which lose
+// tracks with time, always count as changed.
+func sourcesChanged(cache *spotify.PlaylistCache, cfg spotify.SyncConfig) (bool, error) {
+	id, err := spotify.GetID(cfg.Destination)
+	if err != nil {
+		return false, err
+	}
+	stateDir, err := state.Dir()
+	if err != nil {
+		return false, err
+	}
+	st, exists, err := state.Load(stateDir, id)
+	if err != nil || !exists {
+		return true, err
+	}
+	seen := make(map[string]bool)
+	for _, p := range cfg.GetSource().Playlists() {
+		if p.Windowed() {
+			return true, nil
+		}
+		id, err := spotify.GetID(p.ID)
+		if err != nil {
+			return false, err
+		}
+		seen[id] = true
+		snapshotID, err := cache.GetSnapshotID(id)
+		if err != nil {
+			return false, err
+		}
+		if st.Sources[id].SnapshotID != snapshotID {
+			return true, nil
+		}
+	}
+	// a source was dropped from the medley
+	return len(seen) != len(st.Sources), nil
+}
+
+// wait returns how long to sleep until the next medley is due,
+// at most pollInterval so changes to the config are picked up.
+func (w *watcher) wait(now time.Time) time.Duration {
+	wait := pollInterval
+	for _, m := range w.medleys {
+		wait = min(wait, w.next[m.Name].Sub(now))
+	}
+	return max(wait, 0)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/mhborthwick/medley/cli/pkg/spotify"
+	"github.com/mhborthwick/medley/cli/pkg/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatcherLoad(t *testing.T) {
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+	hourly := &spotify.Schedule{Every: &pkl.Duration{Value: 1, Unit: pkl.Hour}}
+	medleys := func(aPlaylists ...string) []spotify.Medley {
+		return []spotify.Medley{
+			{Name: "a", Config: spotify.SyncConfig{MedleyConfig: &spotify.MedleyConfig{Playlists: aPlaylists}, Schedule: hourly}},
+			{Name: "b", Config: spotify.SyncConfig{MedleyConfig: &spotify.MedleyConfig{}, Schedule: hourly}},
+			{Name: "c", Config: spotify.SyncConfig{MedleyConfig: &spotify.MedleyConfig{}, Schedule: hourly}, Reads: []string{"a"}},
+			{Name: "d", Config: spotify.SyncConfig{MedleyConfig: &spotify.MedleyConfig{}}},
+		}
+	}
+	loaded := func() *watcher {
+		w := newWatcher("medleys.pkl", false)
+		assert.Nil(t, w.load(medleys("x"), now))
+		// as if every medley was synced since
+		for _, m := range w.medleys {
+			delete(w.force, m.Name)
+			w.next[m.Name] = later
+		}
+		return w
+	}
+
+	t.Run("syncs every medley with a schedule right away at first", func(t *testing.T) {
+		w := newWatcher("medleys.pkl", false)
+		assert.Nil(t, w.load(medleys("x"), now))
+		assert.Len(t, w.medleys, 3)
+		assert.Len(t, w.all, 4)
+		assert.Equal(t, map[string]bool{"a": true, "b": true, "c": true}, w.force)
+		assert.Equal(t, map[string]time.Time{"a": now, "b": now, "c": now}, w.next)
+	})
+
+	t.Run("keeps schedule of unchanged medleys", func(t *testing.T) {
+		w := loaded()
+		assert.Nil(t, w.load(medleys("x"), now))
+		assert.Empty(t, w.force)
+		assert.Equal(t, map[string]time.Time{"a": later, "b": later, "c": later}, w.next)
+	})
+
+	t.Run("syncs changed medleys and those reading them right away", func(t *testing.T) {
+		w := loaded()
+		assert.Nil(t, w.load(medleys("x", "y"), now))
+		assert.Equal(t, map[string]bool{"a": true, "c": true}, w.force)
+		assert.Equal(t, map[string]time.Time{"a": now, "b": later, "c": now}, w.next)
+	})
+
+	t.Run("returns error without schedules", func(t *testing.T) {
+		w := newWatcher("medleys.pkl", false)
+		err := w.load([]spotify.Medley{{Name: "d"}}, now)
+		assert.EqualError(t, err, "no medley in medleys.pkl has a schedule")
+		assert.Nil(t, w.medleys)
+	})
+}
+
+func TestModuleModTimes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, text string) string {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.WriteFile(path, []byte(text), 0o644))
+		return path
+	}
+	config := write("medleys.pkl", `amends "Medley.pkl"
+
+import "lib/sources.pkl"
+import* "more/*.pkl"
+
+shared = import("pkl:json")
+`)
+	write("Medley.pkl", `module Medley`)
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "lib"), 0o755))
+	sources := write("lib/sources.pkl", `extends "missing.pkl"`)
+
+	t.Run("returns times of every local module imported", func(t *testing.T) {
+		modTimes, err := moduleModTimes(config)
+		assert.Nil(t, err)
+		assert.Len(t, modTimes, 4)
+		assert.Contains(t, modTimes, filepath.Join(dir, "Medley.pkl"))
+		assert.Contains(t, modTimes, sources)
+		assert.True(t, modTimes[filepath.Join(dir, "lib", "missing.pkl")].IsZero())
+	})
+
+	t.Run("changes when an imported module does", func(t *testing.T) {
+		before, err := moduleModTimes(config)
+		assert.Nil(t, err)
+		later := time.Now().Add(time.Hour)
+		assert.Nil(t, os.Chtimes(sources, later, later))
+		after, err := moduleModTimes(config)
+		assert.Nil(t, err)
+		assert.NotEqual(t, before, after)
+		assert.Equal(t, before[config], after[config])
+	})
+
+	t.Run("returns error for a missing config", func(t *testing.T) {
+		_, err := moduleModTimes(filepath.Join(dir, "nope.pkl"))
+		assert.NotNil(t, err)
+	})
+}
+
+func TestWatcherRetry(t *testing.T) {
+	t.Run("backs off with every failure in a row", func(t *testing.T) {
+		now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+		w := newWatcher("medleys.pkl", false)
+		m := spotify.Medley{Name: "a"}
+		w.retry(m, now)
+		assert.Equal(t, now.Add(time.Minute), w.next["a"])
+		w.retry(m, now)
+		assert.Equal(t, now.Add(2*time.Minute), w.next["a"])
+	})
+}
+
+func TestFailedRead(t *testing.T) {
+	medleys := map[string]spotify.Medley{
+		"a": {Name: "a"},
+		"b": {Name: "b", Reads: []string{"a"}},
+		"c": {Name: "c", Reads: []string{"b"}},
+	}
+
+	t.Run("returns failed medley read through another", func(t *testing.T) {
+		assert.Equal(t, "a", failedRead(medleys["c"], medleys, map[string]bool{"a": true}))
+	})
+
+	t.Run("returns empty if nothing read failed", func(t *testing.T) {
+		assert.Equal(t, "", failedRead(medleys["c"], medleys, map[string]bool{"d": true}))
+	})
+}
+
+func TestChangesWithTime(t *testing.T) {
+	medleys := map[string]spotify.Medley{
+		"a": {Name: "a", Config: spotify.SyncConfig{MedleyConfig: &spotify.MedleyConfig{Shuffle: true}}},
+		"b": {Name: "b", Config: spotify.SyncConfig{MedleyConfig: &spotify.MedleyConfig{}}},
+		"c": {Name: "c", Config: spotify.SyncConfig{MedleyConfig: &spotify.MedleyConfig{}}, Reads: []string{"a"}},
+	}
+
+	t.Run("returns true for shuffled medleys and those reading them", func(t *testing.T) {
+		assert.True(t, changesWithTime(medleys["a"], medleys))
+		assert.True(t, changesWithTime(medleys["c"], medleys))
+	})
+
+	t.Run("returns false for medleys that only change with sources", func(t *testing.T) {
+		assert.False(t, changesWithTime(medleys["b"], medleys))
+	})
+}
+
+func TestSourcesChanged(t *testing.T) {
+	destination := "dddddddddddddddddddddd"
+	source := "ssssssssssssssssssssss"
+	other := "oooooooooooooooooooooo"
+	stateDir := t.TempDir()
+	t.Setenv("MEDLEY_STATE_DIR", stateDir)
+	st, _, err := state.Load(stateDir, destination)
+	assert.Nil(t, err)
+	st.Sources[source] = state.Source{SnapshotID: "1"}
+	assert.Nil(t, st.Save(stateDir))
+
+	// every playlist is at snapshot 1, except other at 2
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if strings.Contains(r.URL.Path, other) {
+			w.Write([]byte(`{"snapshot_id": "2"}`))
+			return
+		}
+		w.Write([]byte(`{"snapshot_id": "1"}`))
+	}))
+	defer mockServer.Close()
+	newCache := func() *spotify.PlaylistCache {
+		return spotify.NewPlaylistCache(spotify.Spotify{URL: mockServer.URL, Token: "token", Client: &http.Client{}})
+	}
+
+	t.Run("returns false when no source changed", func(t *testing.T) {
+		changed, err := sourcesChanged(newCache(), spotify.SyncConfig{MedleyConfig: &spotify.MedleyConfig{Playlists: []string{source}}, Destination: destination})
+		assert.Nil(t, err)
+		assert.False(t, changed)
+	})
+
+	t.Run("returns true for a source at another snapshot", func(t *testing.T) {
+		st.Sources[other] = state.Source{SnapshotID: "1"}
+		assert.Nil(t, st.Save(stateDir))
+		defer func() {
+			delete(st.Sources, other)
+			assert.Nil(t, st.Save(stateDir))
+		}()
+		changed, err := sourcesChanged(newCache(), spotify.SyncConfig{MedleyConfig: &spotify.MedleyConfig{Playlists: []string{source, other}}, Destination: destination})
+		assert.Nil(t, err)
+		assert.True(t, changed)
+	})
+
+	t.Run("returns true for a dropped source", func(t *testing.T) {
+		changed, err := sourcesChanged(newCache(), spotify.SyncConfig{MedleyConfig: &spotify.MedleyConfig{}, Destination: destination})
+		assert.Nil(t, err)
+		assert.True(t, changed)
+	})
+
+	t.Run("returns true for a windowed source", func(t *testing.T) {
+		cfg := spotify.SyncConfig{MedleyConfig: &spotify.MedleyConfig{Playlists: []string{source}, AddedSince: "2024-07-01"}, Destination: destination}
+		changed, err := sourcesChanged(newCache(), cfg)
+		assert.Nil(t, err)
+		assert.True(t, changed)
+	})
+
+	t.Run("returns true before the first sync", func(t *testing.T) {
+		changed, err := sourcesChanged(newCache(), spotify.SyncConfig{MedleyConfig: &spotify.MedleyConfig{Playlists: []string{source}}, Destination: other})
+		assert.Nil(t, err)
+		assert.True(t, changed)
+	})
+}
@@ -0,0 +1,27 @@
+package spotify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Archive is where sync puts the tracks it removes from a destination,
+// so nothing disappears for good.
+type Archive struct {
+	// Playlist is the playlist removed tracks are appended to.
+	Playlist string `pkl:"playlist"`
+	// Monthly is the name of a playlist per month, created when first
+	// needed. It is used instead of Playlist when set.
+	Monthly string `pkl:"monthly"`
+}
+
+// Name returns the name of the monthly archive for the month of t,
+// filling in {year}, {month} (e.g. 07) and {monthName} (e.g. July).
+func (a Archive) Name(t time.Time) string {
+	return strings.NewReplacer(
+		"{year}", fmt.Sprintf("%04d", t.Year()),
+		"{month}", fmt.Sprintf("%02d", int(t.Month())),
+		"{monthName}", t.Month().String(),
+	).Replace(a.Monthly)
+}
@@ -0,0 +1,16 @@
+package spotify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveName(t *testing.T) {
+	t.Run("fills in the month", func(t *testing.T) {
+		archive := Archive{Monthly: "Fresh finds {monthName} {year} ({year}-{month})"}
+		name := archive.Name(time.Date(2024, 7, 10, 0, 0, 0, 0, time.UTC))
+		assert.Equal(t, "Fresh finds July 2024 (2024-07)", name)
+	})
+}
@@ -0,0 +1,150 @@
+package spotify
+
+import (
+	"strings"
+	"time"
+)
+
+// PlaylistCache remembers playlists already read from Spotify, so
+// a playlist shared by several medleys is only fetched once per run.
+type PlaylistCache struct {
+	Spotify Spotify
+	// Now is when the run started, which time windows of
+	// sources are relative to so they agree within a run.
+	Now       time.Time
+	snapshots map[string]string
+	items     map[string][]PlaylistItem
+	recent    map[string][]PlaylistItem
+	medleys   map[string][]PlaylistItem
+}
+
+func NewPlaylistCache(s Spotify) *PlaylistCache {
+	return &PlaylistCache{
+		Spotify:   s,
+		Now:       time.Now(),
+		snapshots: make(map[string]string),
+		items:     make(map[string][]PlaylistItem),
+		recent:    make(map[string][]PlaylistItem),
+		medleys:   make(map[string][]PlaylistItem),
+	}
+}
+
+// GetSnapshotID gets the snapshot ID of a playlist,
+// fetching it the first time it is asked for.
+func (c *PlaylistCache) GetSnapshotID(playlistID string) (string, error) {
+	if snapshotID, ok := c.snapshots[playlistID]; ok {
+		return snapshotID, nil
+	}
+	snapshotID, err := c.Spotify.GetSnapshotID(playlistID)
+	if err != nil {
+		return "", err
+	}
+	c.snapshots[playlistID] = snapshotID
+	return snapshotID, nil
+}
+
+// GetAllItems gets the items of a playlist,
+// fetching them the first time they are asked for.
+func (c *PlaylistCache) GetAllItems(playlistID string) ([]PlaylistItem, error) {
+	if items, ok := c.items[playlistID]; ok {
+		return items, nil
+	}
+	items, err := c.Spotify.GetAllItems(playlistID)
+	if err != nil {
+		return nil, err
+	}
+	c.items[playlistID] = items
+	return items, nil
+}
+
+// GetItemsAddedSince gets the items of a playlist added at or after
+// since, from the cached items of the playlist if there are any.
+func (c *PlaylistCache) GetItemsAddedSince(playlistID string, since time.Time) ([]PlaylistItem, error) {
+	if items, ok := c.items[playlistID]; ok {
+		return AddedSince(items, since), nil
+	}
+	key := playlistID + "@" + since.Format(time.RFC3339)
+	if items, ok := c.recent[key]; ok {
+		return items, nil
+	}
+	items, err := c.Spotify.GetItemsAddedSince(playlistID, since)
+	if err != nil {
+		return nil, err
+	}
+	c.recent[key] = items
+	return items, nil
+}
+
+// GetTracks gets the tracks with the given URIs by URI, from the cached
+// playlists that have them, fetching the others. URIs that aren't
+// tracks are left out.
+func (c *PlaylistCache) GetTracks(uris []string) (map[string]Track, error) {
+	wanted := make(map[string]bool, len(uris))
+	for _, uri := range uris {
+		wanted[uri] = true
+	}
+	tracks := make(map[string]Track, len(uris))
+	for _, cached := range []map[string][]PlaylistItem{c.items, c.recent} {
+		for _, items := range cached {
+			for _, item := range items {
+				if wanted[item.Track.URI] {
+					tracks[item.Track.URI] = item.Track
+				}
+			}
+		}
+	}
+	var ids []string
+	for uri := range wanted {
+		if _, ok := tracks[uri]; !ok {
+			ids = append(ids, strings.TrimPrefix(uri, "spotify:track:"))
+		}
+	}
+	if len(ids) == 0 {
+		return tracks, nil
+	}
+	fetched, err := c.Spotify.GetTracks(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range fetched {
+		tracks[t.URI] = t
+	}
+	return tracks, nil
+}
+
+// PutMedley caches the evaluated items of a medley, for the
+// medleys that read it.
+func (c *PlaylistCache) PutMedley(name string, items []PlaylistItem) {
+	c.medleys[name] = items
+}
+
+// GetMedley returns the evaluated items of a medley,
+// if it was evaluated in this run.
+func (c *PlaylistCache) GetMedley(name string) ([]PlaylistItem, bool) {
+	items, ok := c.medleys[name]
+	return items, ok
+}
+
+// Has reports whether the items of a playlist are already cached.
+func (c *PlaylistCache) Has(playlistID string) bool {
+	_, ok := c.items[playlistID]
+	return ok
+}
+
+// Forget drops everything cached of a playlist, e.g. after syncing
+// it, so medleys reading it later in the run fetch it again.
+func (c *PlaylistCache) Forget(playlistID string) {
+	delete(c.snapshots, playlistID)
+	delete(c.items, playlistID)
+	for key := range c.recent {
+		if strings.HasPrefix(key, playlistID+"@") {
+			delete(c.recent, key)
+		}
+	}
+}
+
+// Put caches the items of a playlist that were read from elsewhere,
+// e.g. a source that hasn't changed since the last sync.
+func (c *PlaylistCache) Put(playlistID string, items []PlaylistItem) {
+	c.items[playlistID] = items
+}
@@ -0,0 +1,52 @@
+package spotify
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaylistCache(t *testing.T) {
+	t.Run("fetches each playlist once", func(t *testing.T) {
+		requests := 0
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"items": [{"track": {"uri": "123"}}], "next": null}`))
+		}))
+		defer mockServer.Close()
+		cache := NewPlaylistCache(Spotify{
+			URL:    mockServer.URL,
+			Token:  "token",
+			UserID: "me",
+			Client: &http.Client{},
+		})
+		first, err := cache.GetAllItems("abc")
+		assert.Nil(t, err)
+		second, err := cache.GetAllItems("abc")
+		assert.Nil(t, err)
+		assert.Equal(t, first, second)
+		assert.Equal(t, 1, requests)
+	})
+
+	t.Run("returns put items", func(t *testing.T) {
+		cache := NewPlaylistCache(Spotify{})
+		cache.Put("abc", []PlaylistItem{{Track: Track{URI: "123"}}})
+		assert.True(t, cache.Has("abc"))
+		items, err := cache.GetAllItems("abc")
+		assert.Nil(t, err)
+		assert.Equal(t, []PlaylistItem{{Track: Track{URI: "123"}}}, items)
+	})
+
+	t.Run("forgets playlist", func(t *testing.T) {
+		cache := NewPlaylistCache(Spotify{})
+		cache.Put("abc", []PlaylistItem{{Track: Track{URI: "123"}}})
+		cache.Put("def", []PlaylistItem{{Track: Track{URI: "456"}}})
+		cache.Forget("abc")
+		assert.False(t, cache.Has("abc"))
+		assert.True(t, cache.Has("def"))
+	})
+}
@@ -1,14 +1,170 @@
 package spotify
 
-type CreateConfig struct {
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/apple/pkl-go/pkl"
+)
+
+// MedleyConfig is what a medley is evaluated from, the same for
+// create and sync.
+type MedleyConfig struct {
 	UserID    string   `pkl:"userID"`
 	Token     string   `pkl:"token"`
 	Playlists []string `pkl:"playlists"`
+	// Merge is the strategy Playlists are combined with, MergeConcat
+	// or MergeInterleave since they have no weights of their own.
+	Merge string `pkl:"merge"`
+	// AddedWithin and AddedSince limit every one of Playlists
+	// to tracks added to it recently.
+	AddedWithin *pkl.Duration `pkl:"addedWithin"`
+	AddedSince  string        `pkl:"addedSince"`
+	// Source combines playlists with set operations,
+	// used instead of Playlists when set.
+	Source  Source `pkl:"source"`
+	Shuffle bool   `pkl:"shuffle"`
+	// Seed is the seed of the shuffle, either an int or "daily".
+	Seed    any        `pkl:"seed"`
+	Sort    []SortKey  `pkl:"sort"`
+	Filter  Filter     `pkl:"filter"`
+	Limit   Limit      `pkl:"limit"`
+	Exclude Exclude    `pkl:"exclude"`
+	Pinned  []Pin      `pkl:"pinned"`
+	Dedupe  DedupeRule `pkl:"dedupe"`
+}
+
+// CreateConfig is a medley created as a new playlist. The embedded
+// config, like that of SyncConfig, is never nil once evaluated.
+type CreateConfig struct {
+	*MedleyConfig
 }
 
+// SyncConfig is a medley synced into a destination, or a file of them.
 type SyncConfig struct {
-	UserID      string   `pkl:"userID"`
-	Token       string   `pkl:"token"`
-	Playlists   []string `pkl:"playlists"`
-	Destination string   `pkl:"destination"`
+	*MedleyConfig
+	// Schedule is when medley watch syncs the medley.
+	Schedule         *Schedule `pkl:"schedule"`
+	Destination      string    `pkl:"destination"`
+	MaxDelete        *int      `pkl:"maxDelete"`
+	MaxDeletePercent *float64  `pkl:"maxDeletePercent"`
+	Expire           Expire    `pkl:"expire"`
+	Archive          Archive   `pkl:"archive"`
+	// Medleys are named medleys declared in the same file,
+	// used instead of the rest of the config when set.
+	Medleys map[string]SyncConfig `pkl:"medleys"`
+}
+
+// GetSource returns the source of the config,
+// defaulting to the union of its playlists.
+func (c MedleyConfig) GetSource() Source {
+	if c.Source != nil {
+		return c.Source
+	}
+	return NewPlaylistsSource(c.Playlists, c.Merge, PlaylistSource{AddedWithin: c.AddedWithin, AddedSince: c.AddedSince})
+}
+
+// ShuffleSeed returns the seed to shuffle the medley with.
+func (c SyncConfig) ShuffleSeed(now time.Time) int64 {
+	return shuffleSeed(c.Seed, c.Destination, now)
+}
+
+// Order returns items shuffled and then sorted as the config asks.
+func (c SyncConfig) Order(items []PlaylistItem, now time.Time) ([]PlaylistItem, error) {
+	return order(items, c.Shuffle, c.ShuffleSeed(now), c.Sort)
+}
+
+// Ordered reports whether the config sets the order of the
+// destination rather than just the tracks in it.
+func (c SyncConfig) Ordered() bool {
+	return c.Shuffle || len(c.Sort) > 0
+}
+
+func order(items []PlaylistItem, shuffle bool, seed int64, keys []SortKey) ([]PlaylistItem, error) {
+	if shuffle {
+		items = Shuffle(items, seed)
+	}
+	if len(keys) > 0 {
+		return Sort(items, keys)
+	}
+	return items, nil
+}
+
+// shuffleSeed returns seed if it is an int, a seed that changes daily if
+// it is "daily", and a seed that changes every time if it isn't set.
+func shuffleSeed(seed any, playlist string, now time.Time) int64 {
+	switch seed := seed.(type) {
+	case int:
+		return int64(seed)
+	case string:
+		if seed == "daily" {
+			return DailySeed(playlist, now)
+		}
+	}
+	return now.UnixNano()
+}
+
+// Medley is a sync config along with its name.
+type Medley struct {
+	Name   string
+	Config SyncConfig
+	// Member is the Pkl member of the config file that declares the
+	// medley, or empty if the file is a single medley.
+	Member string
+	// Reads are the names of the medleys it reads.
+	Reads []string
+}
+
+// SelectMedleys returns the medleys to sync from a config file. A file
+// without medleys is a single medley. Otherwise name picks one of them,
+// or all picks every one, in SyncOrder. Medleys inherit userID and
+// token from the file when they don't set their own. Medleys they refer
+// to are resolved and listed in Reads, so their tracks are read as they
+// evaluate in the same run, not from their destinations.
+func SelectMedleys(cfg SyncConfig, name string, all bool) ([]Medley, error) {
+	if len(cfg.Medleys) == 0 {
+		if name != "" {
+			return nil, fmt.Errorf("no medley named %s, config declares a single medley", name)
+		}
+		return []Medley{{Name: cfg.Destination, Config: cfg}}, nil
+	}
+	names, err := SyncOrder(cfg.Medleys)
+	if err != nil {
+		return nil, err
+	}
+	if !all {
+		if name == "" {
+			sorted := slices.Clone(names)
+			slices.Sort(sorted)
+			return nil, fmt.Errorf("config declares %d medleys (%s), pass a name or --all", len(sorted), strings.Join(sorted, ", "))
+		}
+		if _, ok := cfg.Medleys[name]; !ok {
+			return nil, fmt.Errorf("no medley named %s", name)
+		}
+		names = []string{name}
+	} else if name != "" {
+		return nil, errors.New("pass either a name or --all, not both")
+	}
+	medleys := make([]Medley, len(names))
+	for i, n := range names {
+		m := cfg.Medleys[n]
+		// a copy, so cfg is left as it was evaluated
+		base := *m.MedleyConfig
+		m.MedleyConfig = &base
+		if m.UserID == "" {
+			m.UserID = cfg.UserID
+		}
+		if m.Token == "" {
+			m.Token = cfg.Token
+		}
+		reads := dependencies(m.Source)
+		if m.Source != nil {
+			m.Source = ResolveMedleys(m.Source, cfg.Medleys)
+		}
+		medleys[i] = Medley{Name: n, Config: m, Member: "medleys[" + PklString(n) + "]", Reads: reads}
+	}
+	return medleys, nil
 }
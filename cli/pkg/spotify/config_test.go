@@ -0,0 +1,82 @@
+package spotify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectMedleys(t *testing.T) {
+	multi := SyncConfig{
+		MedleyConfig: &MedleyConfig{UserID: "me"},
+		Medleys: map[string]SyncConfig{
+			"b": {MedleyConfig: &MedleyConfig{}, Destination: "456"},
+			"a": {MedleyConfig: &MedleyConfig{UserID: "you"}, Destination: "123"},
+		},
+	}
+
+	t.Run("returns single medley", func(t *testing.T) {
+		cfg := SyncConfig{MedleyConfig: &MedleyConfig{UserID: "me"}, Destination: "123"}
+		medleys, err := SelectMedleys(cfg, "", false)
+		assert.Nil(t, err)
+		assert.Equal(t, []Medley{{Name: "123", Config: cfg}}, medleys)
+	})
+
+	t.Run("returns all medleys sorted by name", func(t *testing.T) {
+		medleys, err := SelectMedleys(multi, "", true)
+		assert.Nil(t, err)
+		assert.Equal(t, []Medley{
+			{Name: "a", Config: SyncConfig{MedleyConfig: &MedleyConfig{UserID: "you"}, Destination: "123"}, Member: `medleys["a"]`},
+			{Name: "b", Config: SyncConfig{MedleyConfig: &MedleyConfig{UserID: "me"}, Destination: "456"}, Member: `medleys["b"]`},
+		}, medleys)
+	})
+
+	t.Run("returns named medley", func(t *testing.T) {
+		medleys, err := SelectMedleys(multi, "b", false)
+		assert.Nil(t, err)
+		assert.Equal(t, []Medley{
+			{Name: "b", Config: SyncConfig{MedleyConfig: &MedleyConfig{UserID: "me"}, Destination: "456"}, Member: `medleys["b"]`},
+		}, medleys)
+	})
+
+	t.Run("returns error for unknown name", func(t *testing.T) {
+		_, err := SelectMedleys(multi, "c", false)
+		assert.EqualError(t, err, "no medley named c")
+	})
+
+	t.Run("returns error without name or all", func(t *testing.T) {
+		_, err := SelectMedleys(multi, "", false)
+		assert.EqualError(t, err, "config declares 2 medleys (a, b), pass a name or --all")
+	})
+
+	t.Run("returns medleys after those they read", func(t *testing.T) {
+		cfg := SyncConfig{
+			MedleyConfig: &MedleyConfig{},
+			Medleys: map[string]SyncConfig{
+				"a": {MedleyConfig: &MedleyConfig{Source: UnionSource{Sources: []Source{MedleySource{Name: "b", Weight: 2}}}}, Destination: "123"},
+				"b": {MedleyConfig: &MedleyConfig{}, Destination: "456"},
+			},
+		}
+		medleys, err := SelectMedleys(cfg, "", true)
+		assert.Nil(t, err)
+		assert.Equal(t, "b", medleys[0].Name)
+		assert.Equal(t, "a", medleys[1].Name)
+		assert.Equal(t, []string{"b"}, medleys[1].Reads)
+		assert.Equal(t, UnionSource{Sources: []Source{MedleySource{Name: "b", Weight: 2, Source: NewPlaylistsSource(nil, "", PlaylistSource{})}}}, medleys[1].Config.Source)
+	})
+}
+
+func TestShuffleSeed(t *testing.T) {
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+
+	t.Run("returns int seed", func(t *testing.T) {
+		cfg := SyncConfig{MedleyConfig: &MedleyConfig{Seed: 42}}
+		assert.Equal(t, int64(42), cfg.ShuffleSeed(now))
+	})
+
+	t.Run("returns daily seed", func(t *testing.T) {
+		cfg := SyncConfig{MedleyConfig: &MedleyConfig{Seed: "daily"}, Destination: "abc"}
+		assert.Equal(t, DailySeed("abc", now), cfg.ShuffleSeed(now))
+	})
+}
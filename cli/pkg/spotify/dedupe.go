@@ -0,0 +1,394 @@
+package spotify
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Ways of telling that two tracks are the same.
+const (
+	// MatchURI matches tracks with the same URI.
+	MatchURI = "uri"
+	// MatchISRC matches recordings with the same ISRC, e.g. a song
+	// released on both a single and an album.
+	MatchISRC = "isrc"
+	// MatchTitle matches tracks with the same normalized title and
+	// first artist, e.g. "Song" and "Song - Remastered 2011".
+	MatchTitle = "title"
+	// MatchFuzzy matches tracks with a similar normalized title and
+	// first artist, e.g. "Dont Stop Me Now" and "Don't Stop Me Now".
+	MatchFuzzy = "fuzzy"
+)
+
+// KeepOldest keeps the occurrence added to the playlist first.
+const KeepOldest = "oldest"
+
+// DuplicateGroup is the positions in a playlist of a track that
+// occurs more than once.
+type DuplicateGroup struct {
+	Keep   int
+	Remove []int
+}
+
+// FindDuplicates returns the tracks of items that occur more than once,
+// matched by rule. Keep is KeepFirst to keep the first occurrence or
+// KeepOldest to keep the one added first.
+func FindDuplicates(items []PlaylistItem, rule DedupeRule, keep string) ([]DuplicateGroup, error) {
+	if keep != KeepFirst && keep != KeepOldest {
+		return nil, fmt.Errorf("unknown keep rule: %s", keep)
+	}
+	// tracks that aren't available have no uri to delete them by
+	available := slices.DeleteFunc(slices.Clone(items), func(item PlaylistItem) bool {
+		return item.Track.URI == ""
+	})
+	positions := make([]int, 0, len(available))
+	for i, item := range items {
+		if item.Track.URI != "" {
+			positions = append(positions, i)
+		}
+	}
+	indices, err := rule.groupIndices(available)
+	if err != nil {
+		return nil, err
+	}
+	var groups []DuplicateGroup
+	for _, group := range indices {
+		if len(group) < 2 {
+			continue
+		}
+		p := make([]int, len(group))
+		for i, j := range group {
+			p[i] = positions[j]
+		}
+		kept := p[0]
+		if keep == KeepOldest {
+			for _, i := range p[1:] {
+				if items[i].AddedTime().Before(items[kept].AddedTime()) {
+					kept = i
+				}
+			}
+		}
+		groups = append(groups, DuplicateGroup{
+			Keep:   kept,
+			Remove: slices.DeleteFunc(p, func(i int) bool { return i == kept }),
+		})
+	}
+	return groups, nil
+}
+
+// MatchKey returns what a track is matched with, one of the Match
+// constants. Tracks without an ISRC are matched by URI instead.
+func MatchKey(t Track, match string) (string, error) {
+	switch match {
+	case MatchURI:
+		return t.URI, nil
+	case MatchISRC:
+		if t.ExternalIDs != nil && t.ExternalIDs.ISRC != "" {
+			return "isrc:" + strings.ToUpper(t.ExternalIDs.ISRC), nil
+		}
+		return t.URI, nil
+	case MatchTitle:
+		artist := ""
+		if len(t.Artists) > 0 {
+			artist = NormalizeTitle(t.Artists[0].Name)
+		}
+		return "title:" + NormalizeTitle(t.Name) + "|" + artist, nil
+	}
+	return "", fmt.Errorf("unknown match: %s", match)
+}
+
+var (
+	// bracketed parts, e.g. "(feat. X)" or "[Live]"
+	bracketed = regexp.MustCompile(`\([^)]*\)|\[[^\]]*\]`)
+	// suffixes after a dash, e.g. " - Remastered 2011" or " - Radio Edit"
+	dashSuffix = regexp.MustCompile(`\s+-\s+.*$`)
+	featuring  = regexp.MustCompile(`\s(feat|ft|featuring)\.?\s.*$`)
+	nonWord    = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+)
+
+// NormalizeTitle returns a title lowercased, without versions, featured
+// artists or punctuation, so different releases of a song compare equal.
+func NormalizeTitle(title string) string {
+	t := strings.ToLower(title)
+	t = bracketed.ReplaceAllString(t, " ")
+	t = dashSuffix.ReplaceAllString(t, "")
+	t = featuring.ReplaceAllString(t, "")
+	t = strings.NewReplacer("'", "", "’", "").Replace(t)
+	t = nonWord.ReplaceAllString(t, " ")
+	return strings.TrimSpace(t)
+}
+
+// DeleteBatches returns the positions of the groups to remove, as
+// requests of up to 100 positions. Later positions come first, so
+// every request leaves the positions of the ones after it as they were.
+func DeleteBatches(items []PlaylistItem, groups []DuplicateGroup) [][]PositionedTrack {
+	var positions []int
+	for _, g := range groups {
+		positions = append(positions, g.Remove...)
+	}
+	slices.Sort(positions)
+	slices.Reverse(positions)
+
+	var batches [][]PositionedTrack
+	for _, batch := range Batches(positions, BatchSize) {
+		var tracks []PositionedTrack
+		index := make(map[string]int)
+		for _, p := range batch {
+			uri := items[p].Track.URI
+			i, ok := index[uri]
+			if !ok {
+				i = len(tracks)
+				index[uri] = i
+				tracks = append(tracks, PositionedTrack{URI: uri})
+			}
+			tracks[i].Positions = append(tracks[i].Positions, p)
+		}
+		batches = append(batches, tracks)
+	}
+	return batches
+}
+
+// Rules for which version of a recording a medley keeps.
+const (
+	// PreferFirst keeps the version that comes first from the sources.
+	PreferFirst = "first"
+	// PreferAlbum keeps album versions over singles and compilations.
+	PreferAlbum = "album"
+	// PreferPopular keeps the most popular version.
+	PreferPopular = "popular"
+)
+
+// DedupeRule drops repeats of a track from a medley, beyond the repeats of
+// the same URI that are always dropped.
+type DedupeRule struct {
+	// By is how tracks are matched, one of the Match constants.
+	By string `pkl:"by"`
+	// Prefer is which version is kept, one of the Prefer constants.
+	Prefer string `pkl:"prefer"`
+	// Similarity is how alike, from 0 to 1, titles and artists
+	// have to be to match with MatchFuzzy, DefaultSimilarity if nil.
+	Similarity *float64 `pkl:"similarity"`
+}
+
+// DefaultSimilarity is the Similarity of a DedupeRule that sets none.
+const DefaultSimilarity = 0.85
+
+// Market returns the market to fetch playlists for, so Spotify relinks
+// tracks to the releases playable there and matching them by ISRC
+// compares what would actually play.
+func (d DedupeRule) Market() string {
+	if d.By == MatchISRC {
+		return "from_token"
+	}
+	return ""
+}
+
+// Apply returns items with every repeat of a track dropped, in order.
+// The version that is kept takes the place of the first of them.
+func (d DedupeRule) Apply(items []PlaylistItem) ([]PlaylistItem, []FilterResult, error) {
+	groups, err := d.Group(items)
+	if err != nil {
+		return nil, nil, err
+	}
+	return d.Pick(groups)
+}
+
+// Group returns items grouped into the versions of each track, in the
+// order each track first occurs.
+func (d DedupeRule) Group(items []PlaylistItem) ([][]PlaylistItem, error) {
+	indices, err := d.groupIndices(items)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([][]PlaylistItem, len(indices))
+	for i, group := range indices {
+		for _, j := range group {
+			groups[i] = append(groups[i], items[j])
+		}
+	}
+	return groups, nil
+}
+
+// groupIndices returns the indices of items grouped like Group.
+func (d DedupeRule) groupIndices(items []PlaylistItem) ([][]int, error) {
+	if d.By == MatchFuzzy {
+		return d.fuzzyGroups(items), nil
+	}
+	by := d.By
+	if by == "" {
+		by = MatchURI
+	}
+	var keys []string
+	groups := make(map[string][]int)
+	for i, item := range items {
+		key, err := MatchKey(item.Track, by)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+	indices := make([][]int, len(keys))
+	for i, key := range keys {
+		indices[i] = groups[key]
+	}
+	return indices, nil
+}
+
+// Pick returns the version to keep of every group, along with how
+// many versions were dropped.
+func (d DedupeRule) Pick(groups [][]PlaylistItem) ([]PlaylistItem, []FilterResult, error) {
+	kept := make([]PlaylistItem, 0, len(groups))
+	removed := 0
+	for _, versions := range groups {
+		item, err := d.prefer(versions)
+		if err != nil {
+			return nil, nil, err
+		}
+		kept = append(kept, item)
+		removed += len(versions) - 1
+	}
+	if d.By == "" || d.By == MatchURI {
+		return kept, nil, nil
+	}
+	return kept, []FilterResult{{Rule: "dedupe." + d.By, Removed: removed}}, nil
+}
+
+// fuzzyGroups groups tracks whose normalized title and first artist are
+// both at least Similarity alike to those of the first track of a group.
+// Tracks are only compared with groups of a similar artist, and those
+// with nothing left of their title once normalized aren't grouped.
+func (d DedupeRule) fuzzyGroups(items []PlaylistItem) [][]int {
+	threshold := DefaultSimilarity
+	if d.Similarity != nil {
+		threshold = *d.Similarity
+	}
+	var titles [][]rune
+	var groups [][]int
+	// groups by the artist of their first track, and the
+	// artists similar to each artist seen, in either order
+	byArtist := make(map[string][]int)
+	similar := make(map[string][]string)
+	for j, item := range items {
+		title := NormalizeTitle(item.Track.Name)
+		if title == "" {
+			titles = append(titles, nil)
+			groups = append(groups, []int{j})
+			continue
+		}
+		artist := ""
+		if len(item.Track.Artists) > 0 {
+			artist = NormalizeTitle(item.Track.Artists[0].Name)
+		}
+		if _, ok := similar[artist]; !ok {
+			for other := range similar {
+				if Similarity(artist, other) >= threshold {
+					similar[artist] = append(similar[artist], other)
+					similar[other] = append(similar[other], artist)
+				}
+			}
+			similar[artist] = append(similar[artist], artist)
+		}
+
+		// the first group whose title is similar enough
+		runes := []rune(title)
+		found := -1
+		for _, other := range similar[artist] {
+			for _, i := range byArtist[other] {
+				if found != -1 && i > found {
+					break
+				}
+				if similarity(runes, titles[i]) >= threshold {
+					found = i
+					break
+				}
+			}
+		}
+		if found != -1 {
+			groups[found] = append(groups[found], j)
+			continue
+		}
+		byArtist[artist] = append(byArtist[artist], len(groups))
+		titles = append(titles, runes)
+		groups = append(groups, []int{j})
+	}
+	return groups
+}
+
+// Similarity returns how alike two strings are, from 0 for nothing
+// in common to 1 for equal, based on their edit distance.
+func Similarity(a, b string) float64 {
+	return similarity([]rune(a), []rune(b))
+}
+
+func similarity(a, b []rune) float64 {
+	longest := max(len(a), len(b))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(a, b))/float64(longest)
+}
+
+// levenshtein returns the number of single rune insertions, deletions
+// and substitutions that turn a into b.
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// prefer returns the version of a track to keep.
+func (d DedupeRule) prefer(versions []PlaylistItem) (PlaylistItem, error) {
+	var better func(a, b Track) bool
+	switch d.Prefer {
+	case "", PreferFirst:
+		return versions[0], nil
+	case PreferAlbum:
+		better = func(a, b Track) bool { return albumRank(a) < albumRank(b) }
+	case PreferPopular:
+		better = func(a, b Track) bool { return a.Popularity > b.Popularity }
+	default:
+		return PlaylistItem{}, fmt.Errorf("unknown prefer rule: %s", d.Prefer)
+	}
+	best := versions[0]
+	for _, v := range versions[1:] {
+		if better(v.Track, best.Track) {
+			best = v
+		}
+	}
+	return best, nil
+}
+
+// albumRank orders album types from most to least preferred.
+func albumRank(t Track) int {
+	if t.Album == nil {
+		return 3
+	}
+	switch t.Album.AlbumType {
+	case "album":
+		return 0
+	case "single":
+		return 1
+	case "compilation":
+		return 2
+	}
+	return 3
+}
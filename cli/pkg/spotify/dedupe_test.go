@@ -0,0 +1,226 @@
+package spotify
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	items := []PlaylistItem{
+		{AddedAt: "2024-07-03T00:00:00Z", Track: Track{URI: "a", ExternalIDs: &ExternalIDs{ISRC: "X1"}}},
+		{AddedAt: "2024-07-02T00:00:00Z", Track: Track{URI: "b"}},
+		{AddedAt: "2024-07-01T00:00:00Z", Track: Track{URI: "a", ExternalIDs: &ExternalIDs{ISRC: "X1"}}},
+		{AddedAt: "2024-07-01T00:00:00Z", Track: Track{URI: "c", ExternalIDs: &ExternalIDs{ISRC: "x1"}}},
+		{AddedAt: "2024-07-04T00:00:00Z", Track: Track{URI: "a", ExternalIDs: &ExternalIDs{ISRC: "X1"}}},
+	}
+
+	t.Run("returns repeats of uri after the first", func(t *testing.T) {
+		groups, err := FindDuplicates(items, DedupeRule{By: MatchURI}, KeepFirst)
+		assert.Nil(t, err)
+		assert.Equal(t, []DuplicateGroup{{Keep: 0, Remove: []int{2, 4}}}, groups)
+	})
+
+	t.Run("returns repeats of isrc", func(t *testing.T) {
+		groups, err := FindDuplicates(items, DedupeRule{By: MatchISRC}, KeepFirst)
+		assert.Nil(t, err)
+		assert.Equal(t, []DuplicateGroup{{Keep: 0, Remove: []int{2, 3, 4}}}, groups)
+	})
+
+	t.Run("keeps oldest occurrence", func(t *testing.T) {
+		groups, err := FindDuplicates(items, DedupeRule{By: MatchISRC}, KeepOldest)
+		assert.Nil(t, err)
+		assert.Equal(t, []DuplicateGroup{{Keep: 2, Remove: []int{0, 3, 4}}}, groups)
+	})
+
+	t.Run("skips unavailable tracks", func(t *testing.T) {
+		withUnavailable := append([]PlaylistItem{{}, {}}, items...)
+		groups, err := FindDuplicates(withUnavailable, DedupeRule{By: MatchURI}, KeepFirst)
+		assert.Nil(t, err)
+		assert.Equal(t, []DuplicateGroup{{Keep: 2, Remove: []int{4, 6}}}, groups)
+	})
+
+	t.Run("returns similar tracks", func(t *testing.T) {
+		similar := []PlaylistItem{
+			{Track: Track{URI: "1", Name: "Dont Stop Me Now", Artists: []Artist{{Name: "Queen"}}}},
+			{Track: Track{URI: "2", Name: "Don't Stop Me Now", Artists: []Artist{{Name: "Queen"}}}},
+		}
+		groups, err := FindDuplicates(similar, DedupeRule{By: MatchFuzzy}, KeepFirst)
+		assert.Nil(t, err)
+		assert.Equal(t, []DuplicateGroup{{Keep: 0, Remove: []int{1}}}, groups)
+	})
+
+	t.Run("returns error for unknown rules", func(t *testing.T) {
+		_, err := FindDuplicates(items, DedupeRule{By: "name"}, KeepFirst)
+		assert.EqualError(t, err, "unknown match: name")
+		_, err = FindDuplicates(items, DedupeRule{By: MatchURI}, "newest")
+		assert.EqualError(t, err, "unknown keep rule: newest")
+	})
+}
+
+func TestNormalizeTitle(t *testing.T) {
+	t.Run("returns title without versions", func(t *testing.T) {
+		tests := map[string]string{
+			"Song":                       "song",
+			"Song - Remastered 2011":     "song",
+			"Song (feat. Someone)":       "song",
+			"Song [Live]":                "song",
+			"Song feat. Someone":         "song",
+			"Don't Stop Me Now":          "dont stop me now",
+			"  Héroes  ":                 "héroes",
+			"Song (Radio Edit) - Single": "song",
+		}
+		for title, want := range tests {
+			assert.Equal(t, want, NormalizeTitle(title), title)
+		}
+	})
+}
+
+func TestMatchKey(t *testing.T) {
+	t.Run("matches titles by first artist", func(t *testing.T) {
+		a, _ := MatchKey(Track{Name: "Song - Remastered", Artists: []Artist{{Name: "X"}}}, MatchTitle)
+		b, _ := MatchKey(Track{Name: "song", Artists: []Artist{{Name: "x"}, {Name: "Y"}}}, MatchTitle)
+		c, _ := MatchKey(Track{Name: "song", Artists: []Artist{{Name: "Y"}}}, MatchTitle)
+		assert.Equal(t, a, b)
+		assert.NotEqual(t, a, c)
+	})
+}
+
+func TestDeleteBatches(t *testing.T) {
+	t.Run("returns later positions first", func(t *testing.T) {
+		items := dated("a", "b", "a", "b", "a")
+		groups := []DuplicateGroup{{Keep: 0, Remove: []int{2, 4}}, {Keep: 1, Remove: []int{3}}}
+		assert.Equal(t, [][]PositionedTrack{{
+			{URI: "a", Positions: []int{4, 2}},
+			{URI: "b", Positions: []int{3}},
+		}}, DeleteBatches(items, groups))
+	})
+
+	t.Run("returns batches of 100 positions", func(t *testing.T) {
+		var uris []string
+		for i := 0; i < 250; i++ {
+			uris = append(uris, fmt.Sprint(i))
+		}
+		groups := []DuplicateGroup{{Keep: 0}}
+		for i := 1; i < 250; i++ {
+			groups[0].Remove = append(groups[0].Remove, i)
+		}
+		batches := DeleteBatches(dated(uris...), groups)
+		assert.Len(t, batches, 3)
+		assert.Len(t, batches[0], 100)
+		assert.Equal(t, []int{249}, batches[0][0].Positions)
+		assert.Len(t, batches[2], 49)
+	})
+}
+
+func TestDedupeRuleApply(t *testing.T) {
+	isrc := &ExternalIDs{ISRC: "X1"}
+	items := []PlaylistItem{
+		{Track: Track{URI: "a"}},
+		{Track: Track{URI: "single", Popularity: 70, ExternalIDs: isrc, Album: &Album{AlbumType: "single"}}},
+		{Track: Track{URI: "b"}},
+		{Track: Track{URI: "album", Popularity: 50, ExternalIDs: isrc, Album: &Album{AlbumType: "album"}}},
+		{Track: Track{URI: "compilation", Popularity: 90, ExternalIDs: isrc, Album: &Album{AlbumType: "compilation"}}},
+	}
+
+	t.Run("keeps first version in place", func(t *testing.T) {
+		kept, results, err := DedupeRule{By: MatchISRC}.Apply(items)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "single", "b"}, uris(kept))
+		assert.Equal(t, []FilterResult{{Rule: "dedupe.isrc", Removed: 2}}, results)
+	})
+
+	t.Run("keeps album version", func(t *testing.T) {
+		kept, _, err := DedupeRule{By: MatchISRC, Prefer: PreferAlbum}.Apply(items)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "album", "b"}, uris(kept))
+	})
+
+	t.Run("keeps most popular version", func(t *testing.T) {
+		kept, _, err := DedupeRule{By: MatchISRC, Prefer: PreferPopular}.Apply(items)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "compilation", "b"}, uris(kept))
+	})
+
+	t.Run("returns items unchanged by uri", func(t *testing.T) {
+		kept, results, err := DedupeRule{}.Apply(items)
+		assert.Nil(t, err)
+		assert.Equal(t, items, kept)
+		assert.Empty(t, results)
+	})
+
+	t.Run("returns error for unknown prefer rule", func(t *testing.T) {
+		_, _, err := DedupeRule{By: MatchISRC, Prefer: "newest"}.Apply(items)
+		assert.EqualError(t, err, "unknown prefer rule: newest")
+	})
+}
+
+func TestDedupeRuleFuzzy(t *testing.T) {
+	track := func(uri, name, artist string) PlaylistItem {
+		return PlaylistItem{Track: Track{URI: uri, Name: name, Artists: []Artist{{Name: artist}}}}
+	}
+	items := []PlaylistItem{
+		track("1", "Dont Stop Me Now", "Queen"),
+		track("2", "Bohemian Rhapsody", "Queen"),
+		track("3", "Don't Stop Me Now - Remastered 2011", "Queen"),
+		track("4", "Don't Stop Me Now", "Queens"),
+		track("5", "Dont Stop Believin", "Journey"),
+	}
+
+	t.Run("groups similar titles and artists", func(t *testing.T) {
+		groups, err := DedupeRule{By: MatchFuzzy}.Group(items)
+		assert.Nil(t, err)
+		assert.Len(t, groups, 4)
+		assert.Equal(t, []string{"1", "3"}, uris(groups[0]))
+		assert.Equal(t, []string{"4"}, uris(groups[2]))
+	})
+
+	t.Run("groups less similar tracks with lower similarity", func(t *testing.T) {
+		similarity := 0.8
+		kept, results, err := DedupeRule{By: MatchFuzzy, Similarity: &similarity}.Apply(items)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1", "2", "5"}, uris(kept))
+		assert.Equal(t, []FilterResult{{Rule: "dedupe.fuzzy", Removed: 2}}, results)
+	})
+
+	t.Run("groups every track of similar artists with similarity of 0", func(t *testing.T) {
+		similarity := 0.0
+		groups, err := DedupeRule{By: MatchFuzzy, Similarity: &similarity}.Group(items)
+		assert.Nil(t, err)
+		assert.Len(t, groups, 1)
+	})
+
+	t.Run("doesn't group titles that normalize to nothing", func(t *testing.T) {
+		untitled := []PlaylistItem{
+			track("1", "(Intro)", "Queen"),
+			track("2", "[Untitled]", "Queen"),
+			track("3", "Bohemian Rhapsody", "Queen"),
+		}
+		groups, err := DedupeRule{By: MatchFuzzy}.Group(untitled)
+		assert.Nil(t, err)
+		assert.Len(t, groups, 3)
+	})
+
+	t.Run("groups with tracks of a similar artist", func(t *testing.T) {
+		similarity := 0.8
+		groups, err := DedupeRule{By: MatchFuzzy, Similarity: &similarity}.Group([]PlaylistItem{
+			track("1", "Bohemian Rhapsody", "Queen"),
+			track("2", "Killer Queen", "Queens"),
+			track("3", "Bohemian Rhapsody", "Queens"),
+		})
+		assert.Nil(t, err)
+		assert.Len(t, groups, 2)
+		assert.Equal(t, []string{"1", "3"}, uris(groups[0]))
+	})
+}
+
+func TestSimilarity(t *testing.T) {
+	t.Run("returns share of runes in common", func(t *testing.T) {
+		assert.Equal(t, 1.0, Similarity("", ""))
+		assert.Equal(t, 1.0, Similarity("queen", "queen"))
+		assert.Equal(t, 0.0, Similarity("abc", ""))
+		assert.InDelta(t, 5.0/6, Similarity("queen", "queens"), 0.001)
+		assert.InDelta(t, 0.75, Similarity("héro", "hero"), 0.001)
+	})
+}
@@ -0,0 +1,127 @@
+package spotify
+
+import "sort"
+
+// MovedTrack is a track that two playlists have in a different order,
+// at its positions in each.
+type MovedTrack struct {
+	Track Track
+	From  int
+	To    int
+}
+
+// PlaylistDiff is how the tracks of two playlists differ.
+type PlaylistDiff struct {
+	OnlyA []PlaylistItem
+	OnlyB []PlaylistItem
+	// Both holds the items of a that b has too
+	Both []PlaylistItem
+	// Moved is only set by DiffPlaylists when ordered
+	Moved []MovedTrack
+}
+
+// DiffPlaylists returns the tracks only a has, only b has and both have,
+// matched by match, one of MatchURI or MatchISRC. Repeats of a track
+// count once, at its first position. With ordered, it also returns the
+// fewest tracks of both that have to move for a to be in the order of b.
+func DiffPlaylists(a, b []PlaylistItem, match string, ordered bool) (PlaylistDiff, error) {
+	keysA, err := firstPositions(a, match)
+	if err != nil {
+		return PlaylistDiff{}, err
+	}
+	keysB, err := firstPositions(b, match)
+	if err != nil {
+		return PlaylistDiff{}, err
+	}
+
+	var diff PlaylistDiff
+	// positions in b of the tracks of a that b has, in the order of a
+	var shared []int
+	var sharedKeys []string
+	for _, k := range keysA.order {
+		i := keysA.at[k]
+		j, ok := keysB.at[k]
+		if !ok {
+			diff.OnlyA = append(diff.OnlyA, a[i])
+			continue
+		}
+		diff.Both = append(diff.Both, a[i])
+		shared = append(shared, j)
+		sharedKeys = append(sharedKeys, k)
+	}
+	for _, k := range keysB.order {
+		if _, ok := keysA.at[k]; !ok {
+			diff.OnlyB = append(diff.OnlyB, b[keysB.at[k]])
+		}
+	}
+	if !ordered {
+		return diff, nil
+	}
+
+	// the most shared tracks already in the order
+	// of b stay, every other one has to move
+	stays := increasing(shared)
+	for n, j := range shared {
+		if stays[n] {
+			continue
+		}
+		diff.Moved = append(diff.Moved, MovedTrack{Track: b[j].Track, From: keysA.at[sharedKeys[n]], To: j})
+	}
+	return diff, nil
+}
+
+// positions is where each track of a playlist first occurs.
+type positions struct {
+	order []string
+	at    map[string]int
+}
+
+// firstPositions returns the first position of each track of items,
+// skipping unavailable tracks, which have no uri.
+func firstPositions(items []PlaylistItem, match string) (positions, error) {
+	p := positions{at: make(map[string]int)}
+	for i, item := range items {
+		if item.Track.URI == "" {
+			continue
+		}
+		k, err := MatchKey(item.Track, match)
+		if err != nil {
+			return positions{}, err
+		}
+		if _, ok := p.at[k]; ok {
+			continue
+		}
+		p.order = append(p.order, k)
+		p.at[k] = i
+	}
+	return p, nil
+}
+
+// increasing returns which of the distinct values of s make up
+// its longest increasing subsequence.
+func increasing(s []int) []bool {
+	// tails[l] is the index in s of the smallest value that
+	// ends an increasing subsequence of length l+1
+	var tails []int
+	prev := make([]int, len(s))
+	for i, v := range s {
+		l := sort.Search(len(tails), func(n int) bool { return s[tails[n]] >= v })
+		prev[i] = -1
+		if l > 0 {
+			prev[i] = tails[l-1]
+		}
+		if l == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[l] = i
+		}
+	}
+	in := make([]bool, len(s))
+	if len(tails) == 0 {
+		return in
+	}
+	for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+		in[i] = true
+	}
+	return in
+}
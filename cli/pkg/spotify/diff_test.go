@@ -0,0 +1,47 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffPlaylists(t *testing.T) {
+	item := func(uri, isrc string) PlaylistItem {
+		return PlaylistItem{Track: Track{URI: uri, ExternalIDs: &ExternalIDs{ISRC: isrc}}}
+	}
+
+	t.Run("returns tracks only in a, only in b and in both", func(t *testing.T) {
+		a := []PlaylistItem{item("1", ""), item("2", ""), item("2", ""), {}, item("3", "")}
+		b := []PlaylistItem{item("3", ""), item("4", ""), item("1", "")}
+		diff, err := DiffPlaylists(a, b, MatchURI, false)
+		assert.Nil(t, err)
+		assert.Equal(t, []PlaylistItem{item("2", "")}, diff.OnlyA)
+		assert.Equal(t, []PlaylistItem{item("4", "")}, diff.OnlyB)
+		assert.Equal(t, []PlaylistItem{item("1", ""), item("3", "")}, diff.Both)
+		assert.Nil(t, diff.Moved)
+	})
+
+	t.Run("matches by isrc", func(t *testing.T) {
+		a := []PlaylistItem{item("1", "X"), item("2", "Y")}
+		b := []PlaylistItem{item("3", "x")}
+		diff, err := DiffPlaylists(a, b, MatchISRC, false)
+		assert.Nil(t, err)
+		assert.Equal(t, []PlaylistItem{item("2", "Y")}, diff.OnlyA)
+		assert.Nil(t, diff.OnlyB)
+		assert.Equal(t, []PlaylistItem{item("1", "X")}, diff.Both)
+	})
+
+	t.Run("returns fewest moved tracks", func(t *testing.T) {
+		a := []PlaylistItem{item("1", ""), item("2", ""), item("3", ""), item("4", ""), item("5", "")}
+		b := []PlaylistItem{item("6", ""), item("2", ""), item("3", ""), item("1", ""), item("4", "")}
+		diff, err := DiffPlaylists(a, b, MatchURI, true)
+		assert.Nil(t, err)
+		assert.Equal(t, []MovedTrack{{Track: Track{URI: "1", ExternalIDs: &ExternalIDs{}}, From: 0, To: 3}}, diff.Moved)
+	})
+
+	t.Run("returns error for unknown match", func(t *testing.T) {
+		_, err := DiffPlaylists([]PlaylistItem{item("1", "")}, nil, "bpm", false)
+		assert.EqualError(t, err, "unknown match: bpm")
+	})
+}
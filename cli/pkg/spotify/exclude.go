@@ -0,0 +1,97 @@
+package spotify
+
+import "fmt"
+
+// Exclude lists tracks that are never in a medley, wherever they
+// come from. Excluded tracks are removed if already there.
+type Exclude struct {
+	// Playlists exclude every track in them.
+	Playlists []string `pkl:"playlists"`
+	// Tracks are track URIs or links.
+	Tracks []string `pkl:"tracks"`
+	// Artists are artist IDs or links.
+	Artists []string `pkl:"artists"`
+}
+
+// Exclusion is an Exclude with its playlists fetched.
+type Exclusion struct {
+	playlists map[string]bool
+	tracks    map[string]bool
+	artists   map[string]bool
+}
+
+// Load fetches the excluded playlists.
+func (e Exclude) Load(cache *PlaylistCache) (Exclusion, error) {
+	x := Exclusion{
+		playlists: make(map[string]bool),
+		tracks:    make(map[string]bool),
+		artists:   make(map[string]bool),
+	}
+	for _, p := range e.Playlists {
+		id, err := GetID(p)
+		if err != nil {
+			return Exclusion{}, fmt.Errorf("invalid exclude.playlists entry %q", p)
+		}
+		items, err := cache.GetAllItems(id)
+		if err != nil {
+			return Exclusion{}, err
+		}
+		for _, item := range items {
+			x.playlists[item.Track.URI] = true
+		}
+	}
+	for _, t := range e.Tracks {
+		id, err := GetID(t)
+		if err != nil {
+			return Exclusion{}, fmt.Errorf("invalid exclude.tracks entry %q", t)
+		}
+		x.tracks["spotify:track:"+id] = true
+	}
+	for _, a := range e.Artists {
+		id, err := GetID(a)
+		if err != nil {
+			return Exclusion{}, fmt.Errorf("invalid exclude.artists entry %q", a)
+		}
+		x.artists[id] = true
+	}
+	return x, nil
+}
+
+// Rule returns which rule excludes t, or "" if t isn't excluded.
+func (x Exclusion) Rule(t Track) string {
+	if x.tracks[t.URI] {
+		return "exclude.tracks"
+	}
+	if x.playlists[t.URI] {
+		return "exclude.playlists"
+	}
+	for _, a := range t.Artists {
+		if x.artists[a.ID] {
+			return "exclude.artists"
+		}
+	}
+	return ""
+}
+
+// Apply returns the items that aren't excluded, along with
+// how many items each rule removed.
+func (x Exclusion) Apply(items []PlaylistItem) ([]PlaylistItem, []FilterResult) {
+	var results []FilterResult
+	index := make(map[string]int)
+	kept := make([]PlaylistItem, 0, len(items))
+	for _, item := range items {
+		rule := x.Rule(item.Track)
+		if rule == "" {
+			kept = append(kept, item)
+			continue
+		}
+		i, ok := index[rule]
+		if !ok {
+			i = len(results)
+			index[rule] = i
+			results = append(results, FilterResult{Rule: rule})
+		}
+		results[i].Removed++
+	}
+	return kept, results
+}
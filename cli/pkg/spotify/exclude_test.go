@@ -0,0 +1,54 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExclusion(t *testing.T) {
+	track := "spotify:track:tttttttttttttttttttttt"
+	artist := "rrrrrrrrrrrrrrrrrrrrrr"
+	items := []PlaylistItem{
+		{Track: Track{URI: "1"}},
+		{Track: Track{URI: track}},
+		{Track: Track{URI: "5", Artists: []Artist{{ID: "x"}, {ID: artist}}}},
+		{Track: Track{URI: "2"}},
+		{Track: Track{URI: "6"}},
+	}
+
+	t.Run("returns items that aren't excluded with rules", func(t *testing.T) {
+		exclude := Exclude{
+			Playlists: []string{playlistA},
+			Tracks:    []string{"https://open.spotify.com/track/tttttttttttttttttttttt"},
+			Artists:   []string{artist},
+		}
+		x, err := exclude.Load(newTestCache())
+		assert.Nil(t, err)
+		kept, results := x.Apply(items)
+		assert.Equal(t, []string{"6"}, uris(kept))
+		assert.Equal(t, []FilterResult{
+			{Rule: "exclude.playlists", Removed: 2},
+			{Rule: "exclude.tracks", Removed: 1},
+			{Rule: "exclude.artists", Removed: 1},
+		}, results)
+	})
+
+	t.Run("returns every item with nothing excluded", func(t *testing.T) {
+		x, err := Exclude{}.Load(newTestCache())
+		assert.Nil(t, err)
+		kept, results := x.Apply(items)
+		assert.Len(t, kept, 5)
+		assert.Empty(t, results)
+	})
+
+	t.Run("returns error for invalid track", func(t *testing.T) {
+		_, err := Exclude{Tracks: []string{"nope"}}.Load(newTestCache())
+		assert.EqualError(t, err, `invalid exclude.tracks entry "nope"`)
+	})
+
+	t.Run("returns error for invalid artist", func(t *testing.T) {
+		_, err := Exclude{Artists: []string{"nope"}}.Load(newTestCache())
+		assert.EqualError(t, err, `invalid exclude.artists entry "nope"`)
+	})
+}
@@ -0,0 +1,33 @@
+package spotify
+
+import (
+	"time"
+
+	"github.com/apple/pkl-go/pkl"
+)
+
+// Expire removes tracks from a destination some time after medley
+// added them, even if they're still in a source.
+type Expire struct {
+	// After is how long a track stays in the destination. Unset
+	// keeps tracks for as long as they're in a source.
+	After *pkl.Duration `pkl:"after"`
+	// Archive is a playlist expired tracks are moved to.
+	Archive string `pkl:"archive"`
+}
+
+// Expired returns the uris of the destination items that were added
+// longer than After before now.
+func (e Expire) Expired(destination []PlaylistItem, now time.Time) []string {
+	if e.After == nil {
+		return nil
+	}
+	cutoff := now.Add(-e.After.GoDuration())
+	var uris []string
+	for _, item := range destination {
+		if item.AddedTime().Before(cutoff) {
+			uris = append(uris, item.Track.URI)
+		}
+	}
+	return uris
+}
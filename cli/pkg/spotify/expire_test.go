@@ -0,0 +1,23 @@
+package spotify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpireExpired(t *testing.T) {
+	now := time.Date(2024, 7, 10, 0, 0, 0, 0, time.UTC)
+	destination := dated("2024-07-01", "2024-07-03", "2024-07-09")
+
+	t.Run("returns items added longer ago than after", func(t *testing.T) {
+		expire := Expire{After: &pkl.Duration{Value: 7, Unit: pkl.Day}}
+		assert.Equal(t, []string{"2024-07-01"}, expire.Expired(destination, now))
+	})
+
+	t.Run("returns nothing without after", func(t *testing.T) {
+		assert.Empty(t, Expire{}.Expired(destination, now))
+	})
+}
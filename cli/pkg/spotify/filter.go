@@ -0,0 +1,130 @@
+package spotify
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/apple/pkl-go/pkl"
+)
+
+// Filter drops tracks from a medley by rule. Unset rules keep every track.
+type Filter struct {
+	AllowExplicit  *bool         `pkl:"allowExplicit"`
+	MinDuration    *pkl.Duration `pkl:"minDuration"`
+	MaxDuration    *pkl.Duration `pkl:"maxDuration"`
+	MinYear        *int          `pkl:"minYear"`
+	MaxYear        *int          `pkl:"maxYear"`
+	MinPopularity  *int          `pkl:"minPopularity"`
+	Artists        []string      `pkl:"artists"`
+	ExcludeArtists []string      `pkl:"excludeArtists"`
+}
+
+// FilterResult is how many tracks a rule of a filter removed.
+type FilterResult struct {
+	Rule    string
+	Removed int
+}
+
+// rule is a named check of a filter that reports whether to keep a track.
+type rule struct {
+	name string
+	keep func(t Track) bool
+}
+
+// Apply returns the items the filter keeps, along with how many items
+// each of its rules removed. An item is only counted by the first rule
+// that removes it.
+func (f Filter) Apply(items []PlaylistItem) ([]PlaylistItem, []FilterResult) {
+	rules := f.rules()
+	results := make([]FilterResult, len(rules))
+	for i, r := range rules {
+		results[i].Rule = r.name
+	}
+	kept := make([]PlaylistItem, 0, len(items))
+	for _, item := range items {
+		removed := false
+		for i, r := range rules {
+			if !r.keep(item.Track) {
+				results[i].Removed++
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			kept = append(kept, item)
+		}
+	}
+	return kept, results
+}
+
+func (f Filter) rules() []rule {
+	var rules []rule
+	if f.AllowExplicit != nil && !*f.AllowExplicit {
+		rules = append(rules, rule{"allowExplicit", func(t Track) bool {
+			return !t.Explicit
+		}})
+	}
+	if f.MinDuration != nil {
+		minMs := f.MinDuration.GoDuration().Milliseconds()
+		rules = append(rules, rule{"minDuration", func(t Track) bool {
+			return int64(t.DurationMs) >= minMs
+		}})
+	}
+	if f.MaxDuration != nil {
+		maxMs := f.MaxDuration.GoDuration().Milliseconds()
+		rules = append(rules, rule{"maxDuration", func(t Track) bool {
+			return int64(t.DurationMs) <= maxMs
+		}})
+	}
+	if f.MinYear != nil {
+		rules = append(rules, rule{"minYear", func(t Track) bool {
+			year, ok := releaseYear(t)
+			return ok && year >= *f.MinYear
+		}})
+	}
+	if f.MaxYear != nil {
+		rules = append(rules, rule{"maxYear", func(t Track) bool {
+			year, ok := releaseYear(t)
+			return ok && year <= *f.MaxYear
+		}})
+	}
+	if f.MinPopularity != nil {
+		rules = append(rules, rule{"minPopularity", func(t Track) bool {
+			return t.Popularity >= *f.MinPopularity
+		}})
+	}
+	if len(f.Artists) > 0 {
+		rules = append(rules, rule{"artists", func(t Track) bool {
+			return hasArtist(t, f.Artists)
+		}})
+	}
+	if len(f.ExcludeArtists) > 0 {
+		rules = append(rules, rule{"excludeArtists", func(t Track) bool {
+			return !hasArtist(t, f.ExcludeArtists)
+		}})
+	}
+	return rules
+}
+
+// hasArtist reports whether any artist of t is in artists,
+// matched by ID or by name ignoring case.
+func hasArtist(t Track, artists []string) bool {
+	for _, a := range t.Artists {
+		if slices.ContainsFunc(artists, func(s string) bool {
+			return s == a.ID || strings.EqualFold(s, a.Name)
+		}) {
+			return true
+		}
+	}
+	return false
+}
+
+func releaseYear(t Track) (int, bool) {
+	date := t.ReleaseDate()
+	if len(date) < 4 {
+		return 0, false
+	}
+	year, err := strconv.Atoi(date[:4])
+	return year, err == nil
+}
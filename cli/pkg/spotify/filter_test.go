@@ -0,0 +1,53 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterApply(t *testing.T) {
+	list := []PlaylistItem{
+		{Track: Track{URI: "1", Explicit: true, DurationMs: 200000, Popularity: 60, Album: &Album{ReleaseDate: "2011-05-01"}, Artists: []Artist{{ID: "x", Name: "X"}}}},
+		{Track: Track{URI: "2", DurationMs: 400000, Popularity: 60, Album: &Album{ReleaseDate: "1999"}, Artists: []Artist{{ID: "y", Name: "Y"}}}},
+		{Track: Track{URI: "3", DurationMs: 180000, Popularity: 10, Album: &Album{ReleaseDate: "2020"}, Artists: []Artist{{ID: "z", Name: "Z"}}}},
+		{Track: Track{URI: "4", DurationMs: 180000, Popularity: 80, Album: &Album{ReleaseDate: "2021"}, Artists: []Artist{{ID: "y", Name: "Y"}}}},
+	}
+
+	t.Run("keeps every track without rules", func(t *testing.T) {
+		kept, results := Filter{}.Apply(list)
+		assert.Equal(t, list, kept)
+		assert.Empty(t, results)
+	})
+
+	t.Run("removes tracks and counts each once", func(t *testing.T) {
+		allowExplicit := false
+		minPopularity := 50
+		filter := Filter{
+			AllowExplicit: &allowExplicit,
+			MaxDuration:   &pkl.Duration{Value: 5, Unit: pkl.Minute},
+			MinPopularity: &minPopularity,
+		}
+		kept, results := filter.Apply(list)
+		assert.Equal(t, []string{"4"}, uris(kept))
+		assert.Equal(t, []FilterResult{
+			{Rule: "allowExplicit", Removed: 1},
+			{Rule: "maxDuration", Removed: 1},
+			{Rule: "minPopularity", Removed: 1},
+		}, results)
+	})
+
+	t.Run("removes tracks outside year range", func(t *testing.T) {
+		minYear, maxYear := 2000, 2020
+		kept, _ := Filter{MinYear: &minYear, MaxYear: &maxYear}.Apply(list)
+		assert.Equal(t, []string{"1", "3"}, uris(kept))
+	})
+
+	t.Run("matches artists by id or name", func(t *testing.T) {
+		kept, _ := Filter{Artists: []string{"x", "z"}}.Apply(list)
+		assert.Equal(t, []string{"1", "3"}, uris(kept))
+		kept, _ = Filter{ExcludeArtists: []string{"y"}}.Apply(list)
+		assert.Equal(t, []string{"1", "3"}, uris(kept))
+	})
+}
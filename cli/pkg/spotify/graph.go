@@ -0,0 +1,106 @@
+package spotify
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// dependencies returns the names of the medleys source reads,
+// in the order they appear.
+func dependencies(source Source) []string {
+	switch s := source.(type) {
+	case MedleySource:
+		return []string{s.Name}
+	case UnionSource:
+		return dependenciesOf(s.Sources)
+	case IntersectSource:
+		return dependenciesOf(s.Sources)
+	case ExceptSource:
+		return append(dependencies(s.From), dependenciesOf(s.Remove)...)
+	}
+	return nil
+}
+
+func dependenciesOf(sources []Source) []string {
+	var names []string
+	for _, source := range sources {
+		names = append(names, dependencies(source)...)
+	}
+	return names
+}
+
+// SyncOrder returns the names of medleys ordered so every medley comes
+// after the medleys it reads, and otherwise by name. It returns an error
+// if a medley reads one that isn't declared, or medleys read each other
+// in a cycle.
+func SyncOrder(medleys map[string]SyncConfig) ([]string, error) {
+	names := make([]string, 0, len(medleys))
+	for n := range medleys {
+		names = append(names, n)
+	}
+	slices.Sort(names)
+
+	var order []string
+	done := make(map[string]bool)
+	// the medleys being visited, in the order they were reached
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		if done[name] {
+			return nil
+		}
+		if i := slices.Index(path, name); i >= 0 {
+			cycle := append(slices.Clone(path[i:]), name)
+			return fmt.Errorf("medleys read each other in a cycle: %s", strings.Join(cycle, " -> "))
+		}
+		path = append(path, name)
+		for _, dep := range dependencies(medleys[name].Source) {
+			if _, ok := medleys[dep]; !ok {
+				return fmt.Errorf("medley %s reads medley %s, which isn't declared", name, dep)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		done[name] = true
+		order = append(order, name)
+		return nil
+	}
+	for _, n := range names {
+		if err := visit(n); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// ResolveMedleys returns source with the source of every medley it reads
+// set, so the playlists those medleys read count as read by source too.
+func ResolveMedleys(source Source, medleys map[string]SyncConfig) Source {
+	switch s := source.(type) {
+	case MedleySource:
+		s.Source = ResolveMedleys(medleys[s.Name].GetSource(), medleys)
+		return s
+	case UnionSource:
+		s.Sources = resolveMedleysOf(s.Sources, medleys)
+		return s
+	case IntersectSource:
+		s.Sources = resolveMedleysOf(s.Sources, medleys)
+		return s
+	case ExceptSource:
+		s.From = ResolveMedleys(s.From, medleys)
+		s.Remove = resolveMedleysOf(s.Remove, medleys)
+		return s
+	}
+	return source
+}
+
+func resolveMedleysOf(sources []Source, medleys map[string]SyncConfig) []Source {
+	resolved := make([]Source, len(sources))
+	for i, source := range sources {
+		resolved[i] = ResolveMedleys(source, medleys)
+	}
+	return resolved
+}
@@ -0,0 +1,67 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncOrder(t *testing.T) {
+	t.Run("returns medleys after those they read", func(t *testing.T) {
+		order, err := SyncOrder(map[string]SyncConfig{
+			"a": {MedleyConfig: &MedleyConfig{Source: ExceptSource{From: MedleySource{Name: "c"}, Remove: []Source{MedleySource{Name: "b"}}}}},
+			"b": {MedleyConfig: &MedleyConfig{Source: IntersectSource{Sources: []Source{MedleySource{Name: "c"}}}}},
+			"c": {MedleyConfig: &MedleyConfig{}},
+			"d": {MedleyConfig: &MedleyConfig{}},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"c", "b", "a", "d"}, order)
+	})
+
+	t.Run("returns error for cycle", func(t *testing.T) {
+		_, err := SyncOrder(map[string]SyncConfig{
+			"a": {MedleyConfig: &MedleyConfig{Source: MedleySource{Name: "b"}}},
+			"b": {MedleyConfig: &MedleyConfig{Source: UnionSource{Sources: []Source{MedleySource{Name: "c"}}}}},
+			"c": {MedleyConfig: &MedleyConfig{Source: MedleySource{Name: "a"}}},
+		})
+		assert.EqualError(t, err, "medleys read each other in a cycle: a -> b -> c -> a")
+	})
+
+	t.Run("returns error for medley reading itself", func(t *testing.T) {
+		_, err := SyncOrder(map[string]SyncConfig{"a": {MedleyConfig: &MedleyConfig{Source: MedleySource{Name: "a"}}}})
+		assert.EqualError(t, err, "medleys read each other in a cycle: a -> a")
+	})
+
+	t.Run("returns error for unknown medley", func(t *testing.T) {
+		_, err := SyncOrder(map[string]SyncConfig{"a": {MedleyConfig: &MedleyConfig{Source: MedleySource{Name: "z"}}}})
+		assert.EqualError(t, err, "medley a reads medley z, which isn't declared")
+	})
+}
+
+func TestResolveMedleys(t *testing.T) {
+	medleys := map[string]SyncConfig{
+		"b": {MedleyConfig: &MedleyConfig{Playlists: []string{playlistB}}, Destination: playlistC},
+		"c": {MedleyConfig: &MedleyConfig{Source: MedleySource{Name: "b"}}},
+	}
+
+	t.Run("reads playlists of medleys read", func(t *testing.T) {
+		source := ExceptSource{From: PlaylistSource{ID: playlistA}, Remove: []Source{MedleySource{Name: "c"}}}
+		resolved := ResolveMedleys(source, medleys)
+		assert.Equal(t, []PlaylistSource{{ID: playlistA}, {ID: playlistB}}, resolved.Playlists())
+		assert.Equal(t, []PlaylistSource{{ID: playlistA}}, Contributing(resolved))
+	})
+
+	t.Run("evaluates medleys as evaluated in the run", func(t *testing.T) {
+		cache := newTestCache()
+		cache.PutMedley("b", []PlaylistItem{{Track: Track{URI: "2"}}, {Track: Track{URI: "3"}}})
+		source := ExceptSource{From: PlaylistSource{ID: playlistA}, Remove: []Source{MedleySource{Name: "b"}}}
+		items, err := ResolveMedleys(source, medleys).Evaluate(cache)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1"}, uris(items))
+	})
+
+	t.Run("returns error when medley wasn't evaluated", func(t *testing.T) {
+		_, err := MedleySource{Name: "b"}.Evaluate(newTestCache())
+		assert.EqualError(t, err, "medley b can only be a source of another medley in the same file")
+	})
+}
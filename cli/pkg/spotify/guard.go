@@ -0,0 +1,45 @@
+package spotify
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultMaxDeletePercent is the share of the destination a sync may
+// remove when the config sets neither maxDelete nor maxDeletePercent.
+const DefaultMaxDeletePercent = 50.0
+
+// CheckDeletions returns an error if a sync looks like it would remove
+// tracks because a source failed to load rather than because they were
+// taken out of a source.
+func CheckDeletions(cfg SyncConfig, removing, destinationSize int, emptySources []string) error {
+	if len(emptySources) > 0 {
+		return fmt.Errorf(
+			"refusing to sync: sources returned no tracks: %s (use --allow-mass-delete to override)",
+			strings.Join(emptySources, ", "),
+		)
+	}
+	if removing == 0 || destinationSize == 0 {
+		return nil
+	}
+	if cfg.MaxDelete != nil && removing > *cfg.MaxDelete {
+		return fmt.Errorf(
+			"refusing to sync: would remove %d tracks, maxDelete is %d (use --allow-mass-delete to override)",
+			removing, *cfg.MaxDelete,
+		)
+	}
+	maxPercent := DefaultMaxDeletePercent
+	if cfg.MaxDeletePercent != nil {
+		maxPercent = *cfg.MaxDeletePercent
+	} else if cfg.MaxDelete != nil {
+		return nil
+	}
+	percent := float64(removing) / float64(destinationSize) * 100
+	if percent > maxPercent {
+		return fmt.Errorf(
+			"refusing to sync: would remove %d of %d tracks (%.0f%%), maxDeletePercent is %.0f%% (use --allow-mass-delete to override)",
+			removing, destinationSize, percent, maxPercent,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckDeletions(t *testing.T) {
+	t.Run("returns nil under default percent", func(t *testing.T) {
+		err := CheckDeletions(SyncConfig{MedleyConfig: &MedleyConfig{}}, 5, 10, nil)
+		assert.Nil(t, err)
+	})
+
+	t.Run("returns error over default percent", func(t *testing.T) {
+		err := CheckDeletions(SyncConfig{MedleyConfig: &MedleyConfig{}}, 6, 10, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("returns error over maxDelete", func(t *testing.T) {
+		maxDelete := 2
+		err := CheckDeletions(SyncConfig{MedleyConfig: &MedleyConfig{}, MaxDelete: &maxDelete}, 3, 100, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("returns nil under maxDelete", func(t *testing.T) {
+		maxDelete := 8
+		err := CheckDeletions(SyncConfig{MedleyConfig: &MedleyConfig{}, MaxDelete: &maxDelete}, 8, 10, nil)
+		assert.Nil(t, err)
+	})
+
+	t.Run("returns error over maxDeletePercent", func(t *testing.T) {
+		maxDeletePercent := 10.0
+		err := CheckDeletions(SyncConfig{MedleyConfig: &MedleyConfig{}, MaxDeletePercent: &maxDeletePercent}, 2, 10, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("returns error for empty sources", func(t *testing.T) {
+		err := CheckDeletions(SyncConfig{MedleyConfig: &MedleyConfig{}}, 0, 10, []string{"abc"})
+		assert.EqualError(t, err, "refusing to sync: sources returned no tracks: abc (use --allow-mass-delete to override)")
+	})
+}
@@ -16,6 +16,22 @@ func GetID(playlist string) (string, error) {
 	return id, nil
 }
 
+// BatchSize is the most tracks Spotify adds or removes in one request.
+const BatchSize = 100
+
+// Batches splits items into batches of at most size, in order.
+func Batches[T any](items []T, size int) [][]T {
+	var batches [][]T
+	for len(items) > size {
+		batches = append(batches, items[:size])
+		items = items[size:]
+	}
+	if len(items) > 0 {
+		batches = append(batches, items)
+	}
+	return batches
+}
+
 // GetURIs parses the Spotify URIs from a list of tracks.
 func GetURIs(body []byte) ([]string, error) {
 	var parsed GetPlaylistItemsResponseBody
@@ -29,6 +45,17 @@ func GetURIs(body []byte) ([]string, error) {
 	return uris, nil
 }
 
+// GetItems parses the playlist items from a list of tracks.
+func GetItems(body []byte) ([]PlaylistItem, error) {
+	var parsed struct {
+		Items []PlaylistItem `json:"items"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, err
+	}
+	return unlink(parsed.Items), nil
+}
+
 // GetNextURL returns the value of 'next' from
 // the response body.
 func GetNextURL(body []byte) (string, error) {
@@ -33,6 +33,17 @@ func TestGetID(t *testing.T) {
 	})
 }
 
+func TestBatches(t *testing.T) {
+	t.Run("splits items into batches of at most size", func(t *testing.T) {
+		assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, Batches([]int{1, 2, 3, 4, 5}, 2))
+		assert.Equal(t, [][]int{{1, 2}}, Batches([]int{1, 2}, 2))
+	})
+
+	t.Run("returns no batches for no items", func(t *testing.T) {
+		assert.Nil(t, Batches([]string{}, 100))
+	})
+}
+
 func TestGetURIs(t *testing.T) {
 	t.Run("returns uris", func(t *testing.T) {
 		data := GetPlaylistItemsResponseBody{
@@ -85,3 +96,20 @@ func TestGetURIs(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestGetItems(t *testing.T) {
+	t.Run("returns items", func(t *testing.T) {
+		body := []byte(`{"items": [{"added_at": "2024-07-01T00:00:00Z", "added_by": {"id": "me"}, "track": {"uri": "123"}}]}`)
+		items, err := GetItems(body)
+		assert.Nil(t, err)
+		assert.Equal(t, items, []PlaylistItem{
+			{AddedAt: "2024-07-01T00:00:00Z", AddedBy: User{ID: "me"}, Track: Track{URI: "123"}},
+		})
+	})
+
+	t.Run("returns err", func(t *testing.T) {
+		invalidJSON := []byte(`invalid`)
+		_, err := GetItems(invalidJSON)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,116 @@
+package spotify
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/apple/pkl-go/pkl"
+)
+
+// Keep rules for choosing which tracks stay when a limit is hit.
+const (
+	// KeepFirst keeps the tracks that come first from the sources.
+	KeepFirst = "first"
+	// KeepRecent keeps the tracks most recently added to their source.
+	KeepRecent = "recent"
+	// KeepPopular keeps the most popular tracks.
+	KeepPopular = "popular"
+)
+
+// Limit caps the size of a medley. Unset caps don't limit it.
+type Limit struct {
+	MaxTracks    *int          `pkl:"maxTracks"`
+	MaxDuration  *pkl.Duration `pkl:"maxDuration"`
+	MaxPerArtist *int          `pkl:"maxPerArtist"`
+	MaxPerSource *int          `pkl:"maxPerSource"`
+	// Keep is the rule for which tracks stay, one of the Keep constants.
+	Keep string `pkl:"keep"`
+}
+
+// Apply returns the items that fit the limit, in their original order,
+// along with how many items each cap removed.
+func (l Limit) Apply(items []PlaylistItem) ([]PlaylistItem, []FilterResult, error) {
+	ranked, err := l.rank(items)
+	if err != nil {
+		return nil, nil, err
+	}
+	removed := make(map[string]int)
+	perSource := make(map[string]int)
+	perArtist := make(map[string]int)
+	tracks := 0
+	var duration time.Duration
+	keep := make([]bool, len(items))
+	for _, i := range ranked {
+		t := items[i].Track
+		trackDuration := time.Duration(t.DurationMs) * time.Millisecond
+		switch {
+		case l.MaxPerSource != nil && perSource[items[i].Source] >= *l.MaxPerSource:
+			removed["maxPerSource"]++
+		case l.MaxPerArtist != nil && slices.ContainsFunc(t.Artists, func(a Artist) bool {
+			return perArtist[a.ID] >= *l.MaxPerArtist
+		}):
+			removed["maxPerArtist"]++
+		case l.MaxTracks != nil && tracks >= *l.MaxTracks:
+			removed["maxTracks"]++
+		// tracks that don't fit are skipped rather than ending
+		// the medley, so shorter ones after them can still fill it
+		case l.MaxDuration != nil && duration+trackDuration > l.MaxDuration.GoDuration():
+			removed["maxDuration"]++
+		default:
+			keep[i] = true
+			perSource[items[i].Source]++
+			for _, a := range t.Artists {
+				perArtist[a.ID]++
+			}
+			tracks++
+			duration += trackDuration
+		}
+	}
+	kept := make([]PlaylistItem, 0, len(items))
+	for i, item := range items {
+		if keep[i] {
+			kept = append(kept, item)
+		}
+	}
+	// only report the caps that are set
+	var results []FilterResult
+	for _, c := range []struct {
+		rule string
+		set  bool
+	}{
+		{"maxPerSource", l.MaxPerSource != nil},
+		{"maxPerArtist", l.MaxPerArtist != nil},
+		{"maxTracks", l.MaxTracks != nil},
+		{"maxDuration", l.MaxDuration != nil},
+	} {
+		if c.set {
+			results = append(results, FilterResult{Rule: c.rule, Removed: removed[c.rule]})
+		}
+	}
+	return kept, results, nil
+}
+
+// rank returns the positions of items in the order the keep rule
+// prefers them.
+func (l Limit) rank(items []PlaylistItem) ([]int, error) {
+	ranked := make([]int, len(items))
+	for i := range ranked {
+		ranked[i] = i
+	}
+	switch l.Keep {
+	case "", KeepFirst:
+	case KeepRecent:
+		slices.SortStableFunc(ranked, func(a, b int) int {
+			return cmp.Compare(items[b].AddedAt, items[a].AddedAt)
+		})
+	case KeepPopular:
+		slices.SortStableFunc(ranked, func(a, b int) int {
+			return cmp.Compare(items[b].Track.Popularity, items[a].Track.Popularity)
+		})
+	default:
+		return nil, fmt.Errorf("unknown keep rule: %s", l.Keep)
+	}
+	return ranked, nil
+}
@@ -0,0 +1,57 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitApply(t *testing.T) {
+	list := []PlaylistItem{
+		{Source: "a", AddedAt: "2024-01-01T00:00:00Z", Track: Track{URI: "1", DurationMs: 60000, Popularity: 10, Artists: []Artist{{ID: "x"}}}},
+		{Source: "a", AddedAt: "2024-03-01T00:00:00Z", Track: Track{URI: "2", DurationMs: 120000, Popularity: 90, Artists: []Artist{{ID: "x"}}}},
+		{Source: "a", AddedAt: "2024-02-01T00:00:00Z", Track: Track{URI: "3", DurationMs: 60000, Popularity: 50, Artists: []Artist{{ID: "y"}}}},
+		{Source: "b", AddedAt: "2024-04-01T00:00:00Z", Track: Track{URI: "4", DurationMs: 60000, Popularity: 20, Artists: []Artist{{ID: "z"}}}},
+	}
+
+	t.Run("keeps every track without caps", func(t *testing.T) {
+		kept, results, err := Limit{}.Apply(list)
+		assert.Nil(t, err)
+		assert.Equal(t, list, kept)
+		assert.Empty(t, results)
+	})
+
+	t.Run("keeps first tracks", func(t *testing.T) {
+		maxTracks := 2
+		kept, results, err := Limit{MaxTracks: &maxTracks}.Apply(list)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1", "2"}, uris(kept))
+		assert.Equal(t, []FilterResult{{Rule: "maxTracks", Removed: 2}}, results)
+	})
+
+	t.Run("keeps most popular tracks in original order", func(t *testing.T) {
+		maxTracks := 2
+		kept, _, err := Limit{MaxTracks: &maxTracks, Keep: KeepPopular}.Apply(list)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"2", "3"}, uris(kept))
+	})
+
+	t.Run("keeps most recent tracks per artist and source", func(t *testing.T) {
+		one, two := 1, 2
+		kept, _, err := Limit{MaxPerArtist: &one, MaxPerSource: &two, Keep: KeepRecent}.Apply(list)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"2", "3", "4"}, uris(kept))
+	})
+
+	t.Run("fills up to duration", func(t *testing.T) {
+		kept, _, err := Limit{MaxDuration: &pkl.Duration{Value: 3, Unit: pkl.Minute}}.Apply(list)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1", "2"}, uris(kept))
+	})
+
+	t.Run("returns error for unknown keep rule", func(t *testing.T) {
+		_, _, err := Limit{Keep: "oldest"}.Apply(list)
+		assert.EqualError(t, err, "unknown keep rule: oldest")
+	})
+}
@@ -0,0 +1,137 @@
+package spotify
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Merge strategies for combining the sources of a union.
+const (
+	// MergeConcat appends each source after the previous one.
+	MergeConcat = "concat"
+	// MergeInterleave takes one track from each source in turn.
+	MergeInterleave = "interleave"
+	// MergeWeighted takes as many tracks from each source
+	// in turn as its weight.
+	MergeWeighted = "weighted"
+	// MergeSample picks the source of each next track at random,
+	// in proportion to the weights of the sources.
+	MergeSample = "sample"
+)
+
+// Merge combines lists of tracks with the given strategy. Weights are
+// per list; a track already taken from an earlier list is skipped.
+func Merge(lists [][]PlaylistItem, weights []int, strategy string, seed int64) ([]PlaylistItem, error) {
+	switch strategy {
+	case "", MergeConcat:
+		var all []PlaylistItem
+		for _, list := range lists {
+			all = append(all, list...)
+		}
+		return Dedupe(all), nil
+	case MergeInterleave:
+		ones := make([]int, len(lists))
+		for i := range ones {
+			ones[i] = 1
+		}
+		return mergeWeighted(lists, ones), nil
+	case MergeWeighted:
+		return mergeWeighted(lists, weights), nil
+	case MergeSample:
+		return mergeSample(lists, weights, seed), nil
+	default:
+		return nil, fmt.Errorf("unknown merge strategy: %s", strategy)
+	}
+}
+
+// merger hands out the unseen tracks of each list in order.
+type merger struct {
+	lists [][]PlaylistItem
+	next  []int
+	seen  map[string]bool
+}
+
+func newMerger(lists [][]PlaylistItem) *merger {
+	return &merger{
+		lists: lists,
+		next:  make([]int, len(lists)),
+		seen:  make(map[string]bool),
+	}
+}
+
+// take returns the next unseen track of list i,
+// or false if the list has run out.
+func (m *merger) take(i int) (PlaylistItem, bool) {
+	for m.next[i] < len(m.lists[i]) {
+		item := m.lists[i][m.next[i]]
+		m.next[i]++
+		if !m.seen[item.Track.URI] {
+			m.seen[item.Track.URI] = true
+			return item, true
+		}
+	}
+	return PlaylistItem{}, false
+}
+
+func (m *merger) done(i int) bool {
+	return m.next[i] >= len(m.lists[i])
+}
+
+func mergeWeighted(lists [][]PlaylistItem, weights []int) []PlaylistItem {
+	m := newMerger(lists)
+	var merged []PlaylistItem
+	for {
+		progressed := false
+		for i := range lists {
+			for n := 0; n < weight(weights, i); n++ {
+				item, ok := m.take(i)
+				if !ok {
+					break
+				}
+				merged = append(merged, item)
+				progressed = true
+			}
+		}
+		if !progressed {
+			return merged
+		}
+	}
+}
+
+func mergeSample(lists [][]PlaylistItem, weights []int, seed int64) []PlaylistItem {
+	m := newMerger(lists)
+	r := rand.New(rand.NewSource(seed))
+	var merged []PlaylistItem
+	for {
+		total := 0
+		for i := range lists {
+			if !m.done(i) {
+				total += weight(weights, i)
+			}
+		}
+		if total == 0 {
+			return merged
+		}
+		pick := r.Intn(total)
+		for i := range lists {
+			if m.done(i) {
+				continue
+			}
+			pick -= weight(weights, i)
+			if pick < 0 {
+				if item, ok := m.take(i); ok {
+					merged = append(merged, item)
+				}
+				break
+			}
+		}
+	}
+}
+
+// weight returns the weight of list i, which is at least 1.
+func weight(weights []int, i int) int {
+	if i < len(weights) && weights[i] > 1 {
+		return weights[i]
+	}
+	return 1
+}
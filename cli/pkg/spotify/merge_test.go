@@ -0,0 +1,53 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func items(uris ...string) []PlaylistItem {
+	items := make([]PlaylistItem, len(uris))
+	for i, uri := range uris {
+		items[i] = PlaylistItem{Track: Track{URI: uri}}
+	}
+	return items
+}
+
+func TestMerge(t *testing.T) {
+	lists := [][]PlaylistItem{
+		items("a1", "a2", "a3", "a4"),
+		items("b1", "a2", "b2"),
+	}
+
+	t.Run("concatenates lists", func(t *testing.T) {
+		merged, err := Merge(lists, nil, MergeConcat, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a1", "a2", "a3", "a4", "b1", "b2"}, uris(merged))
+	})
+
+	t.Run("interleaves lists", func(t *testing.T) {
+		merged, err := Merge(lists, []int{3, 1}, MergeInterleave, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a1", "b1", "a2", "b2", "a3", "a4"}, uris(merged))
+	})
+
+	t.Run("interleaves lists by weight", func(t *testing.T) {
+		merged, err := Merge(lists, []int{2, 1}, MergeWeighted, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a1", "a2", "b1", "a3", "a4", "b2"}, uris(merged))
+	})
+
+	t.Run("samples every track once", func(t *testing.T) {
+		merged, err := Merge(lists, []int{2, 1}, MergeSample, 42)
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, []string{"a1", "a2", "a3", "a4", "b1", "b2"}, uris(merged))
+		again, _ := Merge(lists, []int{2, 1}, MergeSample, 42)
+		assert.Equal(t, merged, again)
+	})
+
+	t.Run("returns error for unknown strategy", func(t *testing.T) {
+		_, err := Merge(lists, nil, "zip", 0)
+		assert.EqualError(t, err, "unknown merge strategy: zip")
+	})
+}
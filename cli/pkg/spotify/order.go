@@ -0,0 +1,92 @@
+package spotify
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"slices"
+	"time"
+)
+
+// Move is a single reorder of a playlist: RangeLength items starting at
+// RangeStart are moved to before the item at InsertBefore.
+type Move struct {
+	RangeStart   int
+	InsertBefore int
+	RangeLength  int
+}
+
+// Shuffle returns items in a random order determined by seed.
+func Shuffle(items []PlaylistItem, seed int64) []PlaylistItem {
+	shuffled := slices.Clone(items)
+	r := rand.New(rand.NewSource(seed))
+	r.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	return shuffled
+}
+
+// DailySeed returns a seed that stays the same for a playlist
+// within a day and changes the next day.
+func DailySeed(playlist string, now time.Time) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(playlist + now.Format(time.DateOnly)))
+	return int64(h.Sum64())
+}
+
+// Arrange returns current with the tracks in order put in that order.
+// Tracks of current that aren't in order, e.g. tracks added by hand,
+// keep their positions.
+func Arrange(current, order []string) []string {
+	inOrder := make(map[string]bool, len(order))
+	for _, uri := range order {
+		inOrder[uri] = true
+	}
+	inCurrent := make(map[string]bool, len(current))
+	for _, uri := range current {
+		inCurrent[uri] = true
+	}
+	arranged := slices.Clone(current)
+	next := 0
+	seen := make(map[string]bool)
+	for i, uri := range current {
+		if !inOrder[uri] || seen[uri] {
+			continue
+		}
+		seen[uri] = true
+		// skip tracks of order that current doesn't have
+		for next < len(order) && !inCurrent[order[next]] {
+			next++
+		}
+		arranged[i] = order[next]
+		next++
+	}
+	return arranged
+}
+
+// Reorder returns the moves that turn current into desired,
+// which must hold the same tracks. Runs of tracks that are already
+// in the desired order are moved together.
+func Reorder(current, desired []string) []Move {
+	cur := slices.Clone(current)
+	var moves []Move
+	for i := 0; i < len(desired) && i < len(cur); i++ {
+		if cur[i] == desired[i] {
+			continue
+		}
+		j := slices.Index(cur[i+1:], desired[i])
+		if j < 0 {
+			continue
+		}
+		j += i + 1
+		length := 1
+		for i+length < len(desired) && j+length < len(cur) && cur[j+length] == desired[i+length] {
+			length++
+		}
+		moves = append(moves, Move{RangeStart: j, InsertBefore: i, RangeLength: length})
+		moved := slices.Clone(cur[j : j+length])
+		cur = slices.Delete(cur, j, j+length)
+		cur = slices.Insert(cur, i, moved...)
+		i += length - 1
+	}
+	return moves
+}
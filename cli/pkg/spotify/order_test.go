@@ -0,0 +1,70 @@
+package spotify
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// apply replays moves the way Spotify applies them.
+func apply(current []string, moves []Move) []string {
+	cur := slices.Clone(current)
+	for _, m := range moves {
+		moved := slices.Clone(cur[m.RangeStart : m.RangeStart+m.RangeLength])
+		cur = slices.Delete(cur, m.RangeStart, m.RangeStart+m.RangeLength)
+		insert := m.InsertBefore
+		if insert > m.RangeStart {
+			insert -= m.RangeLength
+		}
+		cur = slices.Insert(cur, insert, moved...)
+	}
+	return cur
+}
+
+func TestShuffle(t *testing.T) {
+	t.Run("returns same order for same seed", func(t *testing.T) {
+		list := items("1", "2", "3", "4", "5", "6")
+		first := Shuffle(list, 7)
+		assert.Equal(t, first, Shuffle(list, 7))
+		assert.ElementsMatch(t, list, first)
+	})
+}
+
+func TestDailySeed(t *testing.T) {
+	t.Run("changes by day", func(t *testing.T) {
+		morning := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+		evening := time.Date(2024, 7, 1, 20, 0, 0, 0, time.UTC)
+		tomorrow := time.Date(2024, 7, 2, 8, 0, 0, 0, time.UTC)
+		assert.Equal(t, DailySeed("abc", morning), DailySeed("abc", evening))
+		assert.NotEqual(t, DailySeed("abc", morning), DailySeed("abc", tomorrow))
+	})
+}
+
+func TestArrange(t *testing.T) {
+	t.Run("keeps tracks not in order in place", func(t *testing.T) {
+		arranged := Arrange([]string{"1", "x", "2", "3"}, []string{"3", "1", "2"})
+		assert.Equal(t, []string{"3", "x", "1", "2"}, arranged)
+	})
+}
+
+func TestReorder(t *testing.T) {
+	t.Run("returns no moves for same order", func(t *testing.T) {
+		assert.Empty(t, Reorder([]string{"1", "2"}, []string{"1", "2"}))
+	})
+
+	t.Run("moves runs together", func(t *testing.T) {
+		current := []string{"1", "2", "3", "4", "5"}
+		desired := []string{"4", "5", "1", "2", "3"}
+		moves := Reorder(current, desired)
+		assert.Equal(t, []Move{{RangeStart: 3, InsertBefore: 0, RangeLength: 2}}, moves)
+		assert.Equal(t, desired, apply(current, moves))
+	})
+
+	t.Run("returns moves to desired order", func(t *testing.T) {
+		current := []string{"1", "2", "3", "4", "5", "6"}
+		desired := []string{"6", "3", "1", "5", "2", "4"}
+		assert.Equal(t, desired, apply(current, Reorder(current, desired)))
+	})
+}
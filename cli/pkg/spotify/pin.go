@@ -0,0 +1,100 @@
+package spotify
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Pin keeps a track at a fixed position of a medley.
+type Pin struct {
+	// Track is a track URI or link.
+	Track string `pkl:"track" json:"track"`
+	// Position is where the track is kept, starting from 1.
+	Position int `pkl:"position" json:"position"`
+}
+
+// URI returns the URI of the pinned track.
+func (p Pin) URI() (string, error) {
+	id, err := GetID(p.Track)
+	if err != nil {
+		return "", err
+	}
+	return "spotify:track:" + id, nil
+}
+
+// WithPinned returns items with the pinned tracks that aren't
+// in them yet added at the end, except those that are excluded.
+// The tracks are looked up in cache, so they're excluded by
+// artist and have metadata like any other track.
+func WithPinned(items []PlaylistItem, pins []Pin, x Exclusion, cache *PlaylistCache) ([]PlaylistItem, error) {
+	present := uriSet(items)
+	var missing []string
+	for _, p := range pins {
+		uri, err := p.URI()
+		if err != nil {
+			return nil, err
+		}
+		if !present[uri] {
+			present[uri] = true
+			missing = append(missing, uri)
+		}
+	}
+	if len(missing) == 0 {
+		return items, nil
+	}
+	tracks, err := cache.GetTracks(missing)
+	if err != nil {
+		return nil, err
+	}
+	for _, uri := range missing {
+		track, ok := tracks[uri]
+		if !ok {
+			track = Track{URI: uri}
+		}
+		if x.Rule(track) != "" {
+			continue
+		}
+		items = append(items, PlaylistItem{Track: track})
+	}
+	return items, nil
+}
+
+// PinTracks returns uris with the pinned tracks moved to their positions.
+// Pins past the end of uris are kept at the end, and pinned tracks that
+// aren't in uris are skipped.
+func PinTracks(uris []string, pins []Pin) ([]string, error) {
+	present := make(map[string]bool, len(uris))
+	for _, uri := range uris {
+		present[uri] = true
+	}
+	pinned := make(map[string]bool)
+	var ordered []Pin
+	for _, p := range pins {
+		uri, err := p.URI()
+		if err != nil {
+			return nil, err
+		}
+		if present[uri] && !pinned[uri] {
+			pinned[uri] = true
+			ordered = append(ordered, Pin{Track: uri, Position: p.Position})
+		}
+	}
+	slices.SortStableFunc(ordered, func(a, b Pin) int {
+		return cmp.Compare(a.Position, b.Position)
+	})
+	// the first copy of a pinned track is taken out
+	// and put back at its position, any others stay
+	arranged := make([]string, 0, len(uris))
+	for _, uri := range uris {
+		if pinned[uri] {
+			pinned[uri] = false
+			continue
+		}
+		arranged = append(arranged, uri)
+	}
+	for _, p := range ordered {
+		i := min(max(p.Position-1, 0), len(arranged))
+		arranged = slices.Insert(arranged, i, p.Track)
+	}
+	return arranged, nil
+}
@@ -0,0 +1,83 @@
+package spotify
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPinTracks(t *testing.T) {
+	pinned := "spotify:track:pppppppppppppppppppppp"
+	other := "spotify:track:oooooooooooooooooooooo"
+
+	t.Run("moves pinned tracks to their positions", func(t *testing.T) {
+		pins := []Pin{
+			{Track: "https://open.spotify.com/track/pppppppppppppppppppppp", Position: 1},
+			{Track: other, Position: 3},
+		}
+		arranged, err := PinTracks([]string{"a", "b", pinned, other, "c"}, pins)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{pinned, "a", other, "b", "c"}, arranged)
+	})
+
+	t.Run("keeps pins past the end at the end", func(t *testing.T) {
+		arranged, err := PinTracks([]string{pinned, "a", "b"}, []Pin{{Track: pinned, Position: 10}})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "b", pinned}, arranged)
+	})
+
+	t.Run("moves only the first copy", func(t *testing.T) {
+		arranged, err := PinTracks([]string{"a", pinned, "b", pinned}, []Pin{{Track: pinned, Position: 1}})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{pinned, "a", "b", pinned}, arranged)
+	})
+
+	t.Run("skips pinned tracks that aren't there", func(t *testing.T) {
+		arranged, err := PinTracks([]string{"a", "b"}, []Pin{{Track: pinned, Position: 1}})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "b"}, arranged)
+	})
+
+	t.Run("returns error for invalid track", func(t *testing.T) {
+		_, err := PinTracks([]string{"a"}, []Pin{{Track: "nope", Position: 1}})
+		assert.EqualError(t, err, "invalid playlist")
+	})
+}
+
+func TestWithPinned(t *testing.T) {
+	pinned := "spotify:track:pppppppppppppppppppppp"
+	excluded := "spotify:track:eeeeeeeeeeeeeeeeeeeeee"
+	byExcluded := "spotify:track:xxxxxxxxxxxxxxxxxxxxxx"
+	artist := "rrrrrrrrrrrrrrrrrrrrrr"
+
+	// serves the pinned tracks that no cached playlist has
+	var requested string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Query().Get("ids")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"tracks": [{"uri": "` + byExcluded + `", "duration_ms": 1000, "artists": [{"id": "` + artist + `", "name": "R"}]}, null]}`))
+	}))
+	defer mockServer.Close()
+	cache := NewPlaylistCache(Spotify{URL: mockServer.URL, Token: "token", Client: &http.Client{}})
+	cache.Put(playlistA, []PlaylistItem{{Track: Track{URI: pinned, Name: "Pinned", DurationMs: 2000}}})
+
+	t.Run("adds missing pinned tracks unless excluded", func(t *testing.T) {
+		x, err := Exclude{Tracks: []string{excluded}, Artists: []string{artist}}.Load(cache)
+		assert.Nil(t, err)
+		pins := []Pin{
+			{Track: pinned, Position: 1},
+			{Track: excluded, Position: 2},
+			{Track: "spotify:track:aaaaaaaaaaaaaaaaaaaaaa", Position: 3},
+			{Track: byExcluded, Position: 4},
+		}
+		items, err := WithPinned([]PlaylistItem{{Track: Track{URI: "spotify:track:aaaaaaaaaaaaaaaaaaaaaa"}}}, pins, x, cache)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"spotify:track:aaaaaaaaaaaaaaaaaaaaaa", pinned}, uris(items))
+		assert.Equal(t, 2000, items[1].Track.DurationMs)
+		assert.ElementsMatch(t, []string{"eeeeeeeeeeeeeeeeeeeeee", "xxxxxxxxxxxxxxxxxxxxxx"}, strings.Split(requested, ","))
+	})
+}
@@ -3,9 +3,13 @@ package spotify
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -14,6 +18,9 @@ type Spotify struct {
 	Token  string
 	UserID string
 	Client *http.Client
+	// Market, e.g. "from_token", makes Spotify relink tracks
+	// to the releases that are playable there.
+	Market string
 }
 
 type GetPlaylistItemsResponseBody struct {
@@ -22,6 +29,22 @@ type GetPlaylistItemsResponseBody struct {
 	} `json:"items"`
 }
 
+type GetPlaylistItemsPageResponseBody struct {
+	Items []PlaylistItem `json:"items"`
+	Total int            `json:"total"`
+}
+
+type GetTracksResponseBody struct {
+	// Tracks has nil for IDs that aren't tracks
+	Tracks []*Track `json:"tracks"`
+}
+
+// SnapshotResponseBody is the snapshot ID a playlist is at, which
+// Spotify returns when getting or changing it.
+type SnapshotResponseBody struct {
+	SnapshotID string `json:"snapshot_id"`
+}
+
 type CreatePlaylistRequestBody struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
@@ -41,6 +64,24 @@ type DeleteItemsFromPlaylistRequestBody struct {
 	Tracks []Track `json:"tracks"`
 }
 
+type DeleteItemsAtPositionsRequestBody struct {
+	Tracks     []PositionedTrack `json:"tracks"`
+	SnapshotID string            `json:"snapshot_id"`
+}
+
+// PositionedTrack is a track at the given positions of a playlist.
+type PositionedTrack struct {
+	URI       string `json:"uri"`
+	Positions []int  `json:"positions"`
+}
+
+type ReorderPlaylistItemsRequestBody struct {
+	RangeStart   int    `json:"range_start"`
+	InsertBefore int    `json:"insert_before"`
+	RangeLength  int    `json:"range_length"`
+	SnapshotID   string `json:"snapshot_id,omitempty"`
+}
+
 // GetPlaylistItems gets the items (tracks) within a Spotify playlist.
 func (s Spotify) GetPlaylistItems(url string) ([]byte, error) {
 	req, err := http.NewRequest("GET", url, nil)
@@ -59,14 +100,215 @@ func (s Spotify) GetPlaylistItems(url string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	// an unavailable playlist (e.g. made private) comes back as an
+	// error body, which would otherwise parse as zero items
+	if res.StatusCode >= 400 {
+		return nil, fmt.Errorf("get playlist items: %s: %s", res.Status, body)
+	}
 	return body, nil
 }
 
+// GetAllItems gets every item (track) within a Spotify playlist.
+func (s Spotify) GetAllItems(playlistID string) ([]PlaylistItem, error) {
+	var all []PlaylistItem
+	nextURL := fmt.Sprintf("%s/v1/playlists/%s/tracks", s.URL, playlistID)
+	if s.Market != "" {
+		nextURL += "?market=" + url.QueryEscape(s.Market)
+	}
+	// you have to paginate these requests
+	// because spotify caps you at 20 songs per request
+	for nextURL != "" {
+		body, err := s.GetPlaylistItems(nextURL)
+		if err != nil {
+			return nil, err
+		}
+		items, err := GetItems(body)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+		nextURL, err = GetNextURL(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return all, nil
+}
+
+// pageSize is the most items spotify returns per request.
+const pageSize = 100
+
+// getPage gets up to pageSize items of a playlist starting at offset,
+// along with the total number of items in the playlist.
+func (s Spotify) getPage(playlistID string, offset int) ([]PlaylistItem, int, error) {
+	pageURL := fmt.Sprintf("%s/v1/playlists/%s/tracks?offset=%d&limit=%d", s.URL, playlistID, offset, pageSize)
+	if s.Market != "" {
+		pageURL += "&market=" + url.QueryEscape(s.Market)
+	}
+	body, err := s.GetPlaylistItems(pageURL)
+	if err != nil {
+		return nil, 0, err
+	}
+	var parsed GetPlaylistItemsPageResponseBody
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, 0, err
+	}
+	return unlink(parsed.Items), parsed.Total, nil
+}
+
+// GetItemsAddedSince gets the items (tracks) of a playlist that were
+// added to it at or after since. If the playlist is ordered by when its
+// items were added, newest or oldest first, it stops paging as soon as
+// the rest of the playlist is older than since. A first page added all
+// at once, as by a bulk import, is taken to be oldest first, which the
+// last page then confirms or disproves.
+func (s Spotify) GetItemsAddedSince(playlistID string, since time.Time) ([]PlaylistItem, error) {
+	first, total, err := s.getPage(playlistID, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(first) >= total {
+		return AddedSince(first, since), nil
+	}
+	switch {
+	case isOrdered(first, true) && !isOrdered(first, false):
+		// newest first: page forward until the items get too old
+		all := first
+		for len(all) < total && !all[len(all)-1].AddedTime().Before(since) {
+			page, _, err := s.getPage(playlistID, len(all))
+			if err != nil {
+				return nil, err
+			}
+			if len(page) == 0 {
+				break
+			}
+			all = append(all, page...)
+			if !isOrdered(all, true) {
+				return s.getAllAddedSince(playlistID, since)
+			}
+		}
+		return AddedSince(all, since), nil
+	case isOrdered(first, false):
+		// oldest first: page backward from the end until the items get too old
+		var tail []PlaylistItem
+		end := total
+		for end > len(first) {
+			offset := max(len(first), end-pageSize)
+			page, _, err := s.getPage(playlistID, offset)
+			if err != nil {
+				return nil, err
+			}
+			if len(page) == 0 {
+				break
+			}
+			// a full page overlaps the tail already fetched
+			page = page[:min(len(page), end-offset)]
+			tail = append(page, tail...)
+			if !isOrdered(append(slices.Clone(first[len(first)-1:]), tail...), false) {
+				return s.getAllAddedSince(playlistID, since)
+			}
+			if page[0].AddedTime().Before(since) {
+				return AddedSince(tail, since), nil
+			}
+			end = offset
+		}
+		return AddedSince(append(first, tail...), since), nil
+	default:
+		return s.getAllAddedSince(playlistID, since)
+	}
+}
+
+func (s Spotify) getAllAddedSince(playlistID string, since time.Time) ([]PlaylistItem, error) {
+	all, err := s.GetAllItems(playlistID)
+	if err != nil {
+		return nil, err
+	}
+	return AddedSince(all, since), nil
+}
+
+// GetTracks gets the tracks with the given IDs, skipping IDs that aren't
+// tracks. Relinked tracks keep the URI they were asked for by.
+func (s Spotify) GetTracks(ids []string) ([]Track, error) {
+	var tracks []Track
+	// spotify caps you at 50 tracks per request
+	for _, batch := range Batches(ids, 50) {
+		tracksURL := s.URL + "/v1/tracks?ids=" + url.QueryEscape(strings.Join(batch, ","))
+		if s.Market != "" {
+			tracksURL += "&market=" + url.QueryEscape(s.Market)
+		}
+		req, err := http.NewRequest("GET", tracksURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+		req.Header.Set("Content-Type", "application/json")
+		res, err := s.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode >= 400 {
+			return nil, fmt.Errorf("get tracks: %s: %s", res.Status, body)
+		}
+		var parsed GetTracksResponseBody
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			return nil, err
+		}
+		for _, t := range parsed.Tracks {
+			if t == nil {
+				continue
+			}
+			if t.LinkedFrom != nil && t.LinkedFrom.URI != "" {
+				t.URI = t.LinkedFrom.URI
+			}
+			tracks = append(tracks, *t)
+		}
+	}
+	return tracks, nil
+}
+
+// GetSnapshotID gets the current snapshot ID of a Spotify playlist,
+// which changes whenever the playlist does.
+func (s Spotify) GetSnapshotID(playlistID string) (string, error) {
+	req, err := http.NewRequest("GET", s.URL+"/v1/playlists/"+playlistID+"?fields=snapshot_id", nil)
+	if err != nil {
+		return "", err
+	}
+	token := "Bearer " + s.Token
+	req.Header.Set("Authorization", token)
+	req.Header.Set("Content-Type", "application/json")
+	res, err := s.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode >= 400 {
+		return "", fmt.Errorf("get snapshot id: %s: %s", res.Status, body)
+	}
+	var parsed SnapshotResponseBody
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", err
+	}
+	return parsed.SnapshotID, nil
+}
+
 // CreatePlaylist creates a new empty Spotify playlist.
 func (s Spotify) CreatePlaylist() (string, error) {
 	currentTime := time.Now().Unix()
 	currentTimeString := strconv.FormatInt(currentTime, 10)
-	name := "Playlist " + currentTimeString
+	return s.CreateNamedPlaylist("Playlist " + currentTimeString)
+}
+
+// CreateNamedPlaylist creates a new empty Spotify playlist with the given name.
+func (s Spotify) CreateNamedPlaylist(name string) (string, error) {
 	requestData := CreatePlaylistRequestBody{
 		Name:        name,
 		Description: "Created with Playlists Combiner - https://github.com/mhborthwick/spotify-playlists-combiner",
@@ -92,10 +334,16 @@ func (s Spotify) CreatePlaylist() (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if res.StatusCode >= 400 {
+		return "", fmt.Errorf("create playlist: %s: %s", res.Status, body)
+	}
 	var parsed CreatePlaylistResponseBody
 	if err := json.Unmarshal(body, &parsed); err != nil {
 		return "", err
 	}
+	if parsed.ID == "" {
+		return "", fmt.Errorf("create playlist: no playlist id in response: %s", body)
+	}
 	return parsed.ID, nil
 }
 
@@ -133,6 +381,9 @@ func (s Spotify) AddItemsToPlaylist(uris []string, playlistID string, prepend bo
 	if err != nil {
 		return nil, err
 	}
+	if res.StatusCode >= 400 {
+		return nil, fmt.Errorf("add playlist items: %s: %s", res.Status, body)
+	}
 	return body, nil
 }
 
@@ -167,5 +418,85 @@ func (s Spotify) DeleteItemsFromPlaylist(uris []string, playlistID string) ([]by
 	if err != nil {
 		return nil, err
 	}
+	if res.StatusCode >= 400 {
+		return nil, fmt.Errorf("delete playlist items: %s: %s", res.Status, body)
+	}
 	return body, nil
 }
+
+// DeleteItemsAtPositions deletes the items (tracks) at the given positions
+// of a playlist, as it was at snapshotID, and returns the new snapshot ID
+// of the playlist. Other occurrences of the same tracks are kept.
+func (s Spotify) DeleteItemsAtPositions(playlistID string, tracks []PositionedTrack, snapshotID string) (string, error) {
+	requestData := DeleteItemsAtPositionsRequestBody{
+		Tracks:     tracks,
+		SnapshotID: snapshotID,
+	}
+	requestBody, err := json.Marshal(requestData)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("DELETE", s.URL+"/v1/playlists/"+playlistID+"/tracks", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return "", err
+	}
+	token := "Bearer " + s.Token
+	req.Header.Set("Authorization", token)
+	req.Header.Set("Content-Type", "application/json")
+	res, err := s.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode >= 400 {
+		return "", fmt.Errorf("delete playlist items: %s: %s", res.Status, body)
+	}
+	var parsed SnapshotResponseBody
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", err
+	}
+	return parsed.SnapshotID, nil
+}
+
+// ReorderPlaylistItems moves a range of items (tracks) within a playlist
+// and returns the new snapshot ID of the playlist.
+func (s Spotify) ReorderPlaylistItems(playlistID string, move Move, snapshotID string) (string, error) {
+	requestData := ReorderPlaylistItemsRequestBody{
+		RangeStart:   move.RangeStart,
+		InsertBefore: move.InsertBefore,
+		RangeLength:  move.RangeLength,
+		SnapshotID:   snapshotID,
+	}
+	requestBody, err := json.Marshal(requestData)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("PUT", s.URL+"/v1/playlists/"+playlistID+"/tracks", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return "", err
+	}
+	token := "Bearer " + s.Token
+	req.Header.Set("Authorization", token)
+	req.Header.Set("Content-Type", "application/json")
+	res, err := s.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode >= 400 {
+		return "", fmt.Errorf("reorder playlist items: %s: %s", res.Status, body)
+	}
+	var parsed SnapshotResponseBody
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", err
+	}
+	return parsed.SnapshotID, nil
+}
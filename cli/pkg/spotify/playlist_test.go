@@ -1,10 +1,14 @@
 package spotify
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -29,6 +33,204 @@ func TestGetPlaylistItems(t *testing.T) {
 		assert.Equal(t, mockResponse, data)
 		assert.Nil(t, err)
 	})
+
+	t.Run("returns error for unavailable playlist", func(t *testing.T) {
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"status": 404, "message": "Not found."}}`))
+		}))
+		defer mockServer.Close()
+		spotifyClient := Spotify{
+			URL:    mockServer.URL,
+			Token:  "token",
+			UserID: "me",
+			Client: &http.Client{},
+		}
+		url := fmt.Sprintf("%s/v1/playlists/%s/tracks", spotifyClient.URL, "123")
+		_, err := spotifyClient.GetPlaylistItems(url)
+		assert.Error(t, err)
+	})
+}
+
+func TestGetAllItems(t *testing.T) {
+	t.Run("returns items from every page and nil", func(t *testing.T) {
+		var mockServer *httptest.Server
+		mockServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			if r.URL.Query().Get("offset") == "" {
+				next := mockServer.URL + r.URL.Path + "?offset=1"
+				fmt.Fprintf(w, `{"items": [{"track": {"uri": "123"}}], "next": %q}`, next)
+				return
+			}
+			w.Write([]byte(`{"items": [{"track": {"uri": "abc"}}], "next": null}`))
+		}))
+		defer mockServer.Close()
+		spotifyClient := Spotify{
+			URL:    mockServer.URL,
+			Token:  "token",
+			UserID: "me",
+			Client: &http.Client{},
+		}
+		data, err := spotifyClient.GetAllItems("123")
+		assert.Equal(t, []PlaylistItem{
+			{Track: Track{URI: "123"}},
+			{Track: Track{URI: "abc"}},
+		}, data)
+		assert.Nil(t, err)
+	})
+
+	t.Run("returns relinked tracks with their playlist uri", func(t *testing.T) {
+		var market string
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			market = r.URL.Query().Get("market")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"items": [{"track": {"uri": "relinked", "linked_from": {"id": "original", "uri": "original"}}}], "next": null}`))
+		}))
+		defer mockServer.Close()
+		spotifyClient := Spotify{
+			URL:    mockServer.URL,
+			Client: &http.Client{},
+			Market: "from_token",
+		}
+		data, err := spotifyClient.GetAllItems("123")
+		assert.Nil(t, err)
+		assert.Equal(t, "from_token", market)
+		assert.Equal(t, "original", data[0].Track.URI)
+	})
+}
+
+func TestGetItemsAddedSince(t *testing.T) {
+	// serves 250 items, the one at i added day(i) days after
+	// 2024-01-01, in pages of 100 and counts the pages requested
+	newServer := func(day func(i int) int, requests *int) *httptest.Server {
+		start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*requests++
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			var page GetPlaylistItemsPageResponseBody
+			page.Total = 250
+			for i := offset; i < min(offset+100, 250); i++ {
+				date := start.AddDate(0, 0, day(i)).Format(time.RFC3339)
+				page.Items = append(page.Items, PlaylistItem{AddedAt: date, Track: Track{URI: date}})
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(page)
+		}))
+	}
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, 240)
+
+	t.Run("stops paging forward when newest first", func(t *testing.T) {
+		requests := 0
+		mockServer := newServer(func(i int) int { return 249 - i }, &requests)
+		defer mockServer.Close()
+		spotifyClient := Spotify{URL: mockServer.URL, Token: "token", UserID: "me", Client: &http.Client{}}
+		items, err := spotifyClient.GetItemsAddedSince("123", since)
+		assert.Nil(t, err)
+		assert.Len(t, items, 10)
+		assert.Equal(t, 1, requests)
+	})
+
+	t.Run("pages backward when oldest first", func(t *testing.T) {
+		requests := 0
+		mockServer := newServer(func(i int) int { return i }, &requests)
+		defer mockServer.Close()
+		spotifyClient := Spotify{URL: mockServer.URL, Token: "token", UserID: "me", Client: &http.Client{}}
+		items, err := spotifyClient.GetItemsAddedSince("123", since)
+		assert.Nil(t, err)
+		assert.Len(t, items, 10)
+		assert.Equal(t, 2, requests)
+	})
+
+	t.Run("pages backward over more than one page when oldest first", func(t *testing.T) {
+		requests := 0
+		mockServer := newServer(func(i int) int { return i }, &requests)
+		defer mockServer.Close()
+		spotifyClient := Spotify{URL: mockServer.URL, Token: "token", UserID: "me", Client: &http.Client{}}
+		items, err := spotifyClient.GetItemsAddedSince("123", since.AddDate(0, 0, -120))
+		assert.Nil(t, err)
+		assert.Len(t, items, 130)
+		assert.Equal(t, "2024-04-30T00:00:00Z", items[0].AddedAt)
+		assert.Equal(t, 3, requests)
+	})
+
+	t.Run("pages backward when first page was added at once", func(t *testing.T) {
+		requests := 0
+		// a bulk import followed by one track a day
+		mockServer := newServer(func(i int) int { return max(0, i-100) + 140 }, &requests)
+		defer mockServer.Close()
+		spotifyClient := Spotify{URL: mockServer.URL, Token: "token", UserID: "me", Client: &http.Client{}}
+		items, err := spotifyClient.GetItemsAddedSince("123", since)
+		assert.Nil(t, err)
+		assert.Len(t, items, 50)
+		assert.Equal(t, 2, requests)
+	})
+}
+
+func TestGetTracks(t *testing.T) {
+	t.Run("returns tracks in batches of 50", func(t *testing.T) {
+		requests := 0
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			var body GetTracksResponseBody
+			for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+				body.Tracks = append(body.Tracks, &Track{URI: "spotify:track:" + id})
+			}
+			// ids that aren't tracks are null
+			body.Tracks[0] = nil
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(body)
+		}))
+		defer mockServer.Close()
+		spotifyClient := Spotify{URL: mockServer.URL, Token: "token", Client: &http.Client{}}
+		ids := make([]string, 60)
+		for i := range ids {
+			ids[i] = strconv.Itoa(i)
+		}
+		tracks, err := spotifyClient.GetTracks(ids)
+		assert.Nil(t, err)
+		assert.Len(t, tracks, 58)
+		assert.Equal(t, "spotify:track:1", tracks[0].URI)
+		assert.Equal(t, 2, requests)
+	})
+
+	t.Run("returns relinked tracks by the uri asked for", func(t *testing.T) {
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"tracks": [{"uri": "spotify:track:b", "linked_from": {"uri": "spotify:track:a"}}]}`))
+		}))
+		defer mockServer.Close()
+		spotifyClient := Spotify{URL: mockServer.URL, Token: "token", Client: &http.Client{}, Market: "from_token"}
+		tracks, err := spotifyClient.GetTracks([]string{"a"})
+		assert.Nil(t, err)
+		assert.Equal(t, "spotify:track:a", tracks[0].URI)
+	})
+}
+
+func TestGetSnapshotID(t *testing.T) {
+	t.Run("returns snapshot id and nil", func(t *testing.T) {
+		mockResponse := []byte(`{"snapshot_id": "abc"}`)
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write(mockResponse)
+		}))
+		defer mockServer.Close()
+		spotifyClient := Spotify{
+			URL:    mockServer.URL,
+			Token:  "token",
+			UserID: "me",
+			Client: &http.Client{},
+		}
+		data, err := spotifyClient.GetSnapshotID("123")
+		assert.Equal(t, "abc", data)
+		assert.Nil(t, err)
+	})
 }
 
 func TestCreatePlaylist(t *testing.T) {
@@ -50,6 +252,51 @@ func TestCreatePlaylist(t *testing.T) {
 		assert.Equal(t, "123", data)
 		assert.Nil(t, err)
 	})
+
+	t.Run("returns error for failed request", func(t *testing.T) {
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "expired token"}`))
+		}))
+		defer mockServer.Close()
+		spotifyClient := Spotify{URL: mockServer.URL, UserID: "me", Client: &http.Client{}}
+		_, err := spotifyClient.CreatePlaylist()
+		assert.EqualError(t, err, `create playlist: 401 Unauthorized: {"error": "expired token"}`)
+	})
+
+	t.Run("returns error without id", func(t *testing.T) {
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{}`))
+		}))
+		defer mockServer.Close()
+		spotifyClient := Spotify{URL: mockServer.URL, UserID: "me", Client: &http.Client{}}
+		_, err := spotifyClient.CreatePlaylist()
+		assert.EqualError(t, err, `create playlist: no playlist id in response: {}`)
+	})
+}
+
+func TestCreateNamedPlaylist(t *testing.T) {
+	t.Run("creates playlist with name", func(t *testing.T) {
+		var requested CreatePlaylistRequestBody
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&requested)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"id": "123"}`))
+		}))
+		defer mockServer.Close()
+		spotifyClient := Spotify{
+			URL:    mockServer.URL,
+			Token:  "token",
+			UserID: "me",
+			Client: &http.Client{},
+		}
+		data, err := spotifyClient.CreateNamedPlaylist("Archive July 2024")
+		assert.Equal(t, "123", data)
+		assert.Nil(t, err)
+		assert.Equal(t, "Archive July 2024", requested.Name)
+	})
 }
 
 func TestAddItemsToPlaylist(t *testing.T) {
@@ -71,4 +318,86 @@ func TestAddItemsToPlaylist(t *testing.T) {
 		assert.Equal(t, mockResponse, data)
 		assert.Nil(t, err)
 	})
+
+	t.Run("returns error for failed request", func(t *testing.T) {
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": "rate limited"}`))
+		}))
+		defer mockServer.Close()
+		spotifyClient := Spotify{URL: mockServer.URL, Client: &http.Client{}}
+		_, err := spotifyClient.AddItemsToPlaylist([]string{"abc"}, "123", false)
+		assert.EqualError(t, err, `add playlist items: 429 Too Many Requests: {"error": "rate limited"}`)
+	})
+}
+
+func TestDeleteItemsFromPlaylist(t *testing.T) {
+	t.Run("returns error for failed request", func(t *testing.T) {
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error": "server error"}`))
+		}))
+		defer mockServer.Close()
+		spotifyClient := Spotify{URL: mockServer.URL, Client: &http.Client{}}
+		_, err := spotifyClient.DeleteItemsFromPlaylist([]string{"abc"}, "123")
+		assert.EqualError(t, err, `delete playlist items: 500 Internal Server Error: {"error": "server error"}`)
+	})
+}
+
+func TestReorderPlaylistItems(t *testing.T) {
+	t.Run("returns snapshot id and nil", func(t *testing.T) {
+		var requestBody ReorderPlaylistItemsRequestBody
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&requestBody)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"snapshot_id": "def"}`))
+		}))
+		defer mockServer.Close()
+		spotifyClient := Spotify{
+			URL:    mockServer.URL,
+			Token:  "token",
+			UserID: "me",
+			Client: &http.Client{},
+		}
+		data, err := spotifyClient.ReorderPlaylistItems("123", Move{RangeStart: 3, InsertBefore: 0, RangeLength: 2}, "abc")
+		assert.Equal(t, "def", data)
+		assert.Nil(t, err)
+		assert.Equal(t, ReorderPlaylistItemsRequestBody{RangeStart: 3, InsertBefore: 0, RangeLength: 2, SnapshotID: "abc"}, requestBody)
+	})
+}
+
+func TestDeleteItemsAtPositions(t *testing.T) {
+	t.Run("returns snapshot id and nil", func(t *testing.T) {
+		var requestBody DeleteItemsAtPositionsRequestBody
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&requestBody)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"snapshot_id": "def"}`))
+		}))
+		defer mockServer.Close()
+		spotifyClient := Spotify{
+			URL:    mockServer.URL,
+			Token:  "token",
+			UserID: "me",
+			Client: &http.Client{},
+		}
+		tracks := []PositionedTrack{{URI: "abc", Positions: []int{2, 5}}}
+		data, err := spotifyClient.DeleteItemsAtPositions("123", tracks, "abc")
+		assert.Equal(t, "def", data)
+		assert.Nil(t, err)
+		assert.Equal(t, DeleteItemsAtPositionsRequestBody{Tracks: tracks, SnapshotID: "abc"}, requestBody)
+	})
+
+	t.Run("returns error for failed request", func(t *testing.T) {
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "bad snapshot"}`))
+		}))
+		defer mockServer.Close()
+		spotifyClient := Spotify{URL: mockServer.URL, Client: &http.Client{}}
+		_, err := spotifyClient.DeleteItemsAtPositions("123", nil, "abc")
+		assert.EqualError(t, err, `delete playlist items: 400 Bad Request: {"error": "bad snapshot"}`)
+	})
 }
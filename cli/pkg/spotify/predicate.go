@@ -0,0 +1,148 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/apple/pkl-go/pkl"
+)
+
+// Predicate filters tracks with the `where` function of a config,
+// which Pkl evaluates against the metadata of each track.
+type Predicate struct {
+	Evaluator pkl.Evaluator
+	// Path is the path of the config file.
+	Path string
+	// Member is the Pkl member of the config that declares the medley,
+	// e.g. `medleys["july"]`, or empty for a single medley file.
+	Member string
+}
+
+// Apply returns the items the predicate keeps, along with how many it
+// removed. Every item is kept, and no result is returned, if the medley
+// doesn't declare a predicate.
+func (p Predicate) Apply(ctx context.Context, items []PlaylistItem) ([]PlaylistItem, []FilterResult, error) {
+	if len(items) == 0 {
+		return items, nil, nil
+	}
+	module, err := p.module(items)
+	if err != nil {
+		return nil, nil, err
+	}
+	var keep *[]bool
+	if err := p.Evaluator.EvaluateExpression(ctx, pkl.TextSource(module), "keep", &keep); err != nil {
+		return nil, nil, err
+	}
+	if keep == nil {
+		return items, nil, nil
+	}
+	if len(*keep) != len(items) {
+		return nil, nil, fmt.Errorf("predicate returned %d results for %d tracks", len(*keep), len(items))
+	}
+	kept := make([]PlaylistItem, 0, len(items))
+	for i, item := range items {
+		if (*keep)[i] {
+			kept = append(kept, item)
+		}
+	}
+	return kept, []FilterResult{{Rule: "where", Removed: len(items) - len(kept)}}, nil
+}
+
+// module returns a Pkl module that imports the config
+// and applies its predicate to every one of items.
+func (p Predicate) module(items []PlaylistItem) (string, error) {
+	path, err := filepath.Abs(p.Path)
+	if err != nil {
+		return "", err
+	}
+	// configs that don't amend Medley.pkl have no where property
+	where := `config.getPropertyOrNull("where")`
+	if p.Member != "" {
+		where = "config." + p.Member + `.getPropertyOrNull("where")`
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "import %s as config\n\n", PklString((&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()))
+	fmt.Fprintf(&b, "local where = %s\n\n", where)
+	b.WriteString("local tracks = List(\n")
+	for i, item := range items {
+		if i > 0 {
+			b.WriteString(",\n")
+		}
+		b.WriteString("  " + pklTrack(item))
+	}
+	b.WriteString("\n)\n\n")
+	b.WriteString("keep = if (where == null) null else tracks.map((t) -> where.apply(t))\n")
+	return b.String(), nil
+}
+
+// pklTrack returns item as a Pkl object literal.
+func pklTrack(item PlaylistItem) string {
+	t := item.Track
+	artists := make([]string, len(t.Artists))
+	artistIDs := make([]string, len(t.Artists))
+	for i, a := range t.Artists {
+		artists[i] = PklString(a.Name)
+		artistIDs[i] = PklString(a.ID)
+	}
+	album, albumType := "", ""
+	if t.Album != nil {
+		album, albumType = t.Album.Name, t.Album.AlbumType
+	}
+	isrc := ""
+	if t.ExternalIDs != nil {
+		isrc = t.ExternalIDs.ISRC
+	}
+	year := "null"
+	if y, ok := releaseYear(t); ok {
+		year = strconv.Itoa(y)
+	}
+	fields := []string{
+		"uri = " + PklString(t.URI),
+		"name = " + PklString(t.Name),
+		"artists = List(" + strings.Join(artists, ", ") + ")",
+		"artistIDs = List(" + strings.Join(artistIDs, ", ") + ")",
+		"album = " + PklString(album),
+		"albumType = " + PklString(albumType),
+		"releaseDate = " + PklString(t.ReleaseDate()),
+		"releaseYear = " + year,
+		"durationMs = " + strconv.Itoa(t.DurationMs),
+		"popularity = " + strconv.Itoa(t.Popularity),
+		"explicit = " + strconv.FormatBool(t.Explicit),
+		"isrc = " + PklString(isrc),
+		"addedAt = " + PklString(item.AddedAt),
+		"source = " + PklString(item.Source),
+	}
+	return "new Dynamic { " + strings.Join(fields, "; ") + " }"
+}
+
+// PklString returns s as a Pkl string literal.
+func PklString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&b, `\u{%x}`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,37 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPklString(t *testing.T) {
+	t.Run("escapes quotes and control characters", func(t *testing.T) {
+		assert.Equal(t, `"Say \"Hi\"\n\\ \u{1}"`, PklString("Say \"Hi\"\n\\ \x01"))
+	})
+
+	t.Run("escapes interpolation", func(t *testing.T) {
+		assert.Equal(t, `"\\(x)"`, PklString(`\(x)`))
+	})
+}
+
+func TestPredicateModule(t *testing.T) {
+	t.Run("applies member predicate to tracks", func(t *testing.T) {
+		p := Predicate{Path: "/config/medleys.pkl", Member: `medleys["july"]`}
+		module, err := p.module([]PlaylistItem{
+			{Track: Track{URI: "spotify:track:1", Name: "One", DurationMs: 1000, Artists: []Artist{{ID: "x", Name: "X"}}}},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, `import "file:///config/medleys.pkl" as config
+
+local where = config.medleys["july"].getPropertyOrNull("where")
+
+local tracks = List(
+  new Dynamic { uri = "spotify:track:1"; name = "One"; artists = List("X"); artistIDs = List("x"); album = ""; albumType = ""; releaseDate = ""; releaseYear = null; durationMs = 1000; popularity = 0; explicit = false; isrc = ""; addedAt = ""; source = "" }
+)
+
+keep = if (where == null) null else tracks.map((t) -> where.apply(t))
+`, module)
+	})
+}
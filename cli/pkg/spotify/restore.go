@@ -0,0 +1,30 @@
+package spotify
+
+// PlanRestore returns the tracks to remove from and add to a playlist
+// holding current so it holds the same tracks as desired. Spotify removes
+// every occurrence of a track at once, so tracks that occur a different
+// number of times are removed and added back as often as desired has
+// them. Added tracks are in the order of desired.
+func PlanRestore(current, desired []string) (remove, add []string) {
+	have := make(map[string]int)
+	for _, uri := range current {
+		have[uri]++
+	}
+	want := make(map[string]int)
+	for _, uri := range desired {
+		want[uri]++
+	}
+	removed := make(map[string]bool)
+	for _, uri := range current {
+		if have[uri] != want[uri] && !removed[uri] {
+			removed[uri] = true
+			remove = append(remove, uri)
+		}
+	}
+	for _, uri := range desired {
+		if removed[uri] || have[uri] == 0 {
+			add = append(add, uri)
+		}
+	}
+	return remove, add
+}
@@ -0,0 +1,39 @@
+package spotify
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanRestore(t *testing.T) {
+	t.Run("returns tracks to remove and add", func(t *testing.T) {
+		remove, add := PlanRestore([]string{"a", "x", "b"}, []string{"b", "c", "a"})
+		assert.Equal(t, []string{"x"}, remove)
+		assert.Equal(t, []string{"c"}, add)
+	})
+
+	t.Run("removes and re-adds tracks with a different count", func(t *testing.T) {
+		remove, add := PlanRestore([]string{"a", "b", "b"}, []string{"b", "a", "a"})
+		assert.Equal(t, []string{"a", "b"}, remove)
+		assert.Equal(t, []string{"b", "a", "a"}, add)
+	})
+
+	t.Run("restores exactly with reorder", func(t *testing.T) {
+		current := []string{"a", "x", "b", "b", "c"}
+		desired := []string{"c", "b", "d", "a", "d"}
+		remove, add := PlanRestore(current, desired)
+		restored := slices.DeleteFunc(slices.Clone(current), func(uri string) bool {
+			return slices.Contains(remove, uri)
+		})
+		restored = append(restored, add...)
+		assert.Equal(t, desired, apply(restored, Reorder(restored, desired)))
+	})
+
+	t.Run("returns nothing when playlist matches", func(t *testing.T) {
+		remove, add := PlanRestore([]string{"a", "b"}, []string{"b", "a"})
+		assert.Empty(t, remove)
+		assert.Empty(t, add)
+	})
+}
@@ -0,0 +1,172 @@
+package spotify
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apple/pkl-go/pkl"
+)
+
+// Schedule is when medley watch syncs a medley, either every so often
+// or at the times matched by a cron expression.
+type Schedule struct {
+	Every *pkl.Duration `pkl:"every"`
+	// Cron is a standard five field cron expression:
+	// minute, hour, day of month, month and day of week.
+	Cron string `pkl:"cron"`
+}
+
+// maxBackoff is the longest medley watch waits to retry a failed sync.
+const maxBackoff = time.Hour
+
+// Backoff returns how long to wait before retrying a sync
+// that failed the given number of times in a row.
+func Backoff(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	if failures > 6 {
+		return maxBackoff
+	}
+	return min(time.Minute<<(failures-1), maxBackoff)
+}
+
+// Next returns the first time after t the medley is synced.
+func (s Schedule) Next(t time.Time) (time.Time, error) {
+	if s.Every != nil {
+		return t.Add(s.Every.GoDuration()), nil
+	}
+	if s.Cron == "" {
+		return time.Time{}, errors.New("schedule needs either every or cron")
+	}
+	c, err := parseCron(s.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if next, ok := c.next(t.Truncate(time.Minute).Add(time.Minute)); ok {
+		return next, nil
+	}
+	return time.Time{}, fmt.Errorf("cron %q never matches", s.Cron)
+}
+
+// next returns the first time from t that matches the cron, skipping
+// whole months, days and hours that don't match rather than each of
+// their minutes, and false if there is none.
+func (c cron) next(t time.Time) (time.Time, bool) {
+	// 8 years covers a leap day skipped by
+	// a century, so any expression that can
+	// match has by then
+	for end := t.AddDate(8, 0, 0); t.Before(end); {
+		y, m, d := t.Date()
+		var next time.Time
+		switch {
+		case !c.month[int(m)]:
+			next = time.Date(y, m+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchesDay(t):
+			next = time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+		case !c.hour[t.Hour()]:
+			next = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+		case !c.minute[t.Minute()]:
+			next = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+		// a midnight skipped by daylight saving can
+		// normalize to before t, which mustn't loop
+		if !next.After(t) {
+			next = t.Add(time.Minute)
+		}
+		t = next
+	}
+	return time.Time{}, false
+}
+
+// cron is a parsed cron expression, with the values each field allows.
+type cron struct {
+	minute, hour, dom, month, dow map[int]bool
+	// domAny and dowAny are set for fields that are *, as a day
+	// matches either of dom and dow when both are restricted
+	domAny, dowAny bool
+}
+
+func parseCron(expr string) (cron, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return cron{}, fmt.Errorf("cron %q needs 5 fields, has %d", expr, len(fields))
+	}
+	var c cron
+	var err error
+	bounds := []struct {
+		set      *map[int]bool
+		min, max int
+	}{
+		{&c.minute, 0, 59},
+		{&c.hour, 0, 23},
+		{&c.dom, 1, 31},
+		{&c.month, 1, 12},
+		{&c.dow, 0, 7},
+	}
+	for i, b := range bounds {
+		if *b.set, err = parseCronField(fields[i], b.min, b.max); err != nil {
+			return cron{}, fmt.Errorf("cron %q: %w", expr, err)
+		}
+	}
+	// 7 is sunday too
+	if c.dow[7] {
+		c.dow[0] = true
+	}
+	c.domAny = fields[2] == "*"
+	c.dowAny = fields[4] == "*"
+	return c, nil
+}
+
+// parseCronField parses a comma separated list of *, values and
+// ranges, each optionally with a /step.
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if before, after, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(after)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid step %q", part)
+			}
+			rng, step = before, n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return nil, fmt.Errorf("invalid value %q", part)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+			if lo < min || hi > max || lo > hi {
+				return nil, fmt.Errorf("value %q out of range %d-%d", part, min, max)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// matchesDay reports whether the day of t matches the cron.
+func (c cron) matchesDay(t time.Time) bool {
+	dom, dow := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
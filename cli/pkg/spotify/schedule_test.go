@@ -0,0 +1,70 @@
+package spotify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// a wednesday
+	now := time.Date(2024, 7, 10, 6, 30, 15, 0, time.UTC)
+
+	t.Run("returns time after interval", func(t *testing.T) {
+		next, err := Schedule{Every: &pkl.Duration{Value: 2, Unit: pkl.Hour}}.Next(now)
+		assert.Nil(t, err)
+		assert.Equal(t, now.Add(2*time.Hour), next)
+	})
+
+	t.Run("returns next time matching cron", func(t *testing.T) {
+		tests := map[string]time.Time{
+			"* * * * *":      time.Date(2024, 7, 10, 6, 31, 0, 0, time.UTC),
+			"0 6 * * *":      time.Date(2024, 7, 11, 6, 0, 0, 0, time.UTC),
+			"*/15 * * * *":   time.Date(2024, 7, 10, 6, 45, 0, 0, time.UTC),
+			"0 9-17/4 * * *": time.Date(2024, 7, 10, 9, 0, 0, 0, time.UTC),
+			"0 0 * * 1,7":    time.Date(2024, 7, 14, 0, 0, 0, 0, time.UTC),
+			"0 0 1 * *":      time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC),
+			"0 0 1 * 5":      time.Date(2024, 7, 12, 0, 0, 0, 0, time.UTC),
+			"0 0 29 2 *":     time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+			"59 23 31 12 *":  time.Date(2024, 12, 31, 23, 59, 0, 0, time.UTC),
+		}
+		for expr, want := range tests {
+			next, err := Schedule{Cron: expr}.Next(now)
+			assert.Nil(t, err, expr)
+			assert.Equal(t, want, next, expr)
+		}
+	})
+
+	t.Run("returns leap day after a century without one", func(t *testing.T) {
+		next, err := Schedule{Cron: "0 0 29 2 *"}.Next(time.Date(2096, 3, 1, 0, 0, 0, 0, time.UTC))
+		assert.Nil(t, err)
+		assert.Equal(t, time.Date(2104, 2, 29, 0, 0, 0, 0, time.UTC), next)
+	})
+
+	t.Run("returns error for invalid cron", func(t *testing.T) {
+		_, err := Schedule{Cron: "0 6 * *"}.Next(now)
+		assert.EqualError(t, err, `cron "0 6 * *" needs 5 fields, has 4`)
+		_, err = Schedule{Cron: "0 24 * * *"}.Next(now)
+		assert.EqualError(t, err, `cron "0 24 * * *": value "24" out of range 0-23`)
+		_, err = Schedule{Cron: "0 0 31 2 *"}.Next(now)
+		assert.EqualError(t, err, `cron "0 0 31 2 *" never matches`)
+	})
+
+	t.Run("returns error without every or cron", func(t *testing.T) {
+		_, err := Schedule{}.Next(now)
+		assert.EqualError(t, err, "schedule needs either every or cron")
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	t.Run("doubles up to an hour", func(t *testing.T) {
+		assert.Equal(t, time.Duration(0), Backoff(0))
+		assert.Equal(t, time.Minute, Backoff(1))
+		assert.Equal(t, 4*time.Minute, Backoff(3))
+		assert.Equal(t, 32*time.Minute, Backoff(6))
+		assert.Equal(t, time.Hour, Backoff(7))
+		assert.Equal(t, time.Hour, Backoff(100))
+	})
+}
@@ -0,0 +1,79 @@
+package spotify
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SortKey is a sort rule for the tracks of a medley.
+// Keys after the first break ties of the ones before.
+type SortKey struct {
+	// By is one of "addedAt", "releaseDate", "popularity",
+	// "duration", "artist", "name" or "source".
+	By         string `pkl:"by"`
+	Descending bool   `pkl:"descending"`
+}
+
+// Sort returns items stably sorted by keys. The "source" key sorts by
+// position in items, which is the order the sources produced them in.
+func Sort(items []PlaylistItem, keys []SortKey) ([]PlaylistItem, error) {
+	compares := make([]func(a, b int) int, len(keys))
+	for i, key := range keys {
+		compare, err := comparer(items, key.By)
+		if err != nil {
+			return nil, err
+		}
+		if key.Descending {
+			ascending := compare
+			compare = func(a, b int) int { return -ascending(a, b) }
+		}
+		compares[i] = compare
+	}
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		for _, compare := range compares {
+			if c := compare(a, b); c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+	sorted := make([]PlaylistItem, len(items))
+	for i, j := range order {
+		sorted[i] = items[j]
+	}
+	return sorted, nil
+}
+
+// comparer returns a comparison of the items at two positions by key.
+func comparer(items []PlaylistItem, by string) (func(a, b int) int, error) {
+	switch by {
+	case "addedAt":
+		return func(a, b int) int { return cmp.Compare(items[a].AddedAt, items[b].AddedAt) }, nil
+	case "releaseDate":
+		return func(a, b int) int {
+			return cmp.Compare(items[a].Track.ReleaseDate(), items[b].Track.ReleaseDate())
+		}, nil
+	case "popularity":
+		return func(a, b int) int { return cmp.Compare(items[a].Track.Popularity, items[b].Track.Popularity) }, nil
+	case "duration":
+		return func(a, b int) int { return cmp.Compare(items[a].Track.DurationMs, items[b].Track.DurationMs) }, nil
+	case "artist":
+		return func(a, b int) int {
+			return cmp.Compare(strings.ToLower(items[a].Track.Artist()), strings.ToLower(items[b].Track.Artist()))
+		}, nil
+	case "name":
+		return func(a, b int) int {
+			return cmp.Compare(strings.ToLower(items[a].Track.Name), strings.ToLower(items[b].Track.Name))
+		}, nil
+	case "source":
+		return cmp.Compare[int], nil
+	default:
+		return nil, fmt.Errorf("unknown sort key: %s", by)
+	}
+}
@@ -0,0 +1,38 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSort(t *testing.T) {
+	list := []PlaylistItem{
+		{Track: Track{URI: "1", Name: "b", Popularity: 10, Album: &Album{ReleaseDate: "2011"}}},
+		{Track: Track{URI: "2", Name: "A", Popularity: 50, Album: &Album{ReleaseDate: "1999-05-01"}}},
+		{Track: Track{URI: "3", Name: "c", Popularity: 10, Album: &Album{ReleaseDate: "2020-01"}}},
+	}
+
+	t.Run("sorts by name ignoring case", func(t *testing.T) {
+		sorted, err := Sort(list, []SortKey{{By: "name"}})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"2", "1", "3"}, uris(sorted))
+	})
+
+	t.Run("sorts descending with tie-breakers", func(t *testing.T) {
+		sorted, err := Sort(list, []SortKey{{By: "popularity", Descending: true}, {By: "releaseDate", Descending: true}})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"2", "3", "1"}, uris(sorted))
+	})
+
+	t.Run("keeps source order for ties", func(t *testing.T) {
+		sorted, err := Sort(list, []SortKey{{By: "popularity"}})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1", "3", "2"}, uris(sorted))
+	})
+
+	t.Run("returns error for unknown key", func(t *testing.T) {
+		_, err := Sort(list, []SortKey{{By: "tempo"}})
+		assert.EqualError(t, err, "unknown sort key: tempo")
+	})
+}
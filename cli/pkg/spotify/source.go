@@ -0,0 +1,289 @@
+package spotify
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/apple/pkl-go/pkl"
+)
+
+func init() {
+	pkl.RegisterMapping("Medley#Playlist", PlaylistSource{})
+	pkl.RegisterMapping("Medley#Union", UnionSource{})
+	pkl.RegisterMapping("Medley#Intersect", IntersectSource{})
+	pkl.RegisterMapping("Medley#Except", ExceptSource{})
+	pkl.RegisterMapping("Medley#MedleyRef", MedleySource{})
+}
+
+// Source is an expression of playlists that evaluates
+// to the tracks a medley is built from.
+type Source interface {
+	// Playlists returns every playlist the source reads.
+	Playlists() []PlaylistSource
+	// GetWeight returns how often the source is picked
+	// relative to the other sources of a weighted union.
+	GetWeight() int
+	// Evaluate returns the tracks of the source in order,
+	// without duplicates.
+	Evaluate(cache *PlaylistCache) ([]PlaylistItem, error)
+}
+
+// PlaylistSource is the tracks of a single playlist, optionally only
+// those added to it within AddedWithin or since AddedSince.
+type PlaylistSource struct {
+	ID          string        `pkl:"id"`
+	Weight      int           `pkl:"weight"`
+	AddedWithin *pkl.Duration `pkl:"addedWithin"`
+	AddedSince  string        `pkl:"addedSince"`
+}
+
+// UnionSource is the tracks found in any of its sources,
+// combined with one of the Merge strategies.
+type UnionSource struct {
+	Sources []Source `pkl:"sources"`
+	Merge   string   `pkl:"merge"`
+	Seed    int64    `pkl:"seed"`
+	Weight  int      `pkl:"weight"`
+}
+
+// IntersectSource is the tracks found in every one of its sources.
+type IntersectSource struct {
+	Sources []Source `pkl:"sources"`
+	Weight  int      `pkl:"weight"`
+}
+
+// ExceptSource is the tracks of From that are in none of Remove.
+type ExceptSource struct {
+	From   Source   `pkl:"from"`
+	Remove []Source `pkl:"remove"`
+	Weight int      `pkl:"weight"`
+}
+
+// MedleySource is the tracks of another medley declared in the same
+// file, as that medley evaluates in the same run, never what its
+// destination holds. The medley has to be put in the cache first.
+type MedleySource struct {
+	Name   string `pkl:"name"`
+	Weight int    `pkl:"weight"`
+	// Source is the source of the medley, set by ResolveMedleys
+	// so the playlists it reads are known before evaluating it.
+	Source Source
+}
+
+func (s PlaylistSource) GetWeight() int  { return s.Weight }
+func (s UnionSource) GetWeight() int     { return s.Weight }
+func (s IntersectSource) GetWeight() int { return s.Weight }
+func (s ExceptSource) GetWeight() int    { return s.Weight }
+func (s MedleySource) GetWeight() int    { return s.Weight }
+
+func (s PlaylistSource) Playlists() []PlaylistSource {
+	return []PlaylistSource{s}
+}
+
+// Windowed reports whether the source only has recently added tracks.
+func (s PlaylistSource) Windowed() bool {
+	return s.AddedWithin != nil || s.AddedSince != ""
+}
+
+// Since returns the earliest time a track can have been added to the
+// playlist to be part of the source. When both AddedWithin and
+// AddedSince are set, the later of the two applies.
+func (s PlaylistSource) Since(now time.Time) (time.Time, error) {
+	var since time.Time
+	if s.AddedWithin != nil {
+		since = now.Add(-s.AddedWithin.GoDuration())
+	}
+	if s.AddedSince != "" {
+		t, err := parseSince(s.AddedSince)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if t.After(since) {
+			since = t
+		}
+	}
+	return since, nil
+}
+
+func (s PlaylistSource) Evaluate(cache *PlaylistCache) ([]PlaylistItem, error) {
+	id, err := GetID(s.ID)
+	if err != nil {
+		return nil, err
+	}
+	var items []PlaylistItem
+	if s.Windowed() {
+		since, err := s.Since(cache.Now)
+		if err != nil {
+			return nil, err
+		}
+		items, err = cache.GetItemsAddedSince(id, since)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		items, err = cache.GetAllItems(id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// copy so the cached items aren't tagged
+	// with a source by a medley sharing them
+	tagged := make([]PlaylistItem, len(items))
+	for i, item := range items {
+		item.Source = id
+		tagged[i] = item
+	}
+	return Dedupe(tagged), nil
+}
+
+func (s UnionSource) Playlists() []PlaylistSource {
+	return playlists(s.Sources)
+}
+
+func (s UnionSource) Evaluate(cache *PlaylistCache) ([]PlaylistItem, error) {
+	lists := make([][]PlaylistItem, len(s.Sources))
+	weights := make([]int, len(s.Sources))
+	for i, source := range s.Sources {
+		items, err := source.Evaluate(cache)
+		if err != nil {
+			return nil, err
+		}
+		lists[i] = items
+		weights[i] = source.GetWeight()
+	}
+	return Merge(lists, weights, s.Merge, s.Seed)
+}
+
+func (s IntersectSource) Playlists() []PlaylistSource {
+	return playlists(s.Sources)
+}
+
+func (s IntersectSource) Evaluate(cache *PlaylistCache) ([]PlaylistItem, error) {
+	if len(s.Sources) == 0 {
+		return nil, nil
+	}
+	result, err := s.Sources[0].Evaluate(cache)
+	if err != nil {
+		return nil, err
+	}
+	for _, source := range s.Sources[1:] {
+		items, err := source.Evaluate(cache)
+		if err != nil {
+			return nil, err
+		}
+		result = keep(result, uriSet(items), true)
+	}
+	return result, nil
+}
+
+func (s ExceptSource) Playlists() []PlaylistSource {
+	return append(s.From.Playlists(), playlists(s.Remove)...)
+}
+
+func (s ExceptSource) Evaluate(cache *PlaylistCache) ([]PlaylistItem, error) {
+	result, err := s.From.Evaluate(cache)
+	if err != nil {
+		return nil, err
+	}
+	for _, source := range s.Remove {
+		items, err := source.Evaluate(cache)
+		if err != nil {
+			return nil, err
+		}
+		result = keep(result, uriSet(items), false)
+	}
+	return result, nil
+}
+
+func (s MedleySource) Playlists() []PlaylistSource {
+	if s.Source == nil {
+		return nil
+	}
+	return s.Source.Playlists()
+}
+
+func (s MedleySource) Evaluate(cache *PlaylistCache) ([]PlaylistItem, error) {
+	items, ok := cache.GetMedley(s.Name)
+	if !ok {
+		return nil, fmt.Errorf("medley %s can only be a source of another medley in the same file", s.Name)
+	}
+	return items, nil
+}
+
+// Dedupe removes every repeat of a track after its first occurrence.
+func Dedupe(items []PlaylistItem) []PlaylistItem {
+	seen := make(map[string]bool)
+	unique := make([]PlaylistItem, 0, len(items))
+	for _, item := range items {
+		if !seen[item.Track.URI] {
+			seen[item.Track.URI] = true
+			unique = append(unique, item)
+		}
+	}
+	return unique
+}
+
+// NewPlaylistsSource returns the union of playlists merged with
+// strategy, which is how medleys combine sources by default. Every
+// playlist gets the time window of window.
+func NewPlaylistsSource(playlists []string, strategy string, window PlaylistSource) Source {
+	sources := make([]Source, len(playlists))
+	for i, p := range playlists {
+		sources[i] = PlaylistSource{ID: p, AddedWithin: window.AddedWithin, AddedSince: window.AddedSince}
+	}
+	return UnionSource{Sources: sources, Merge: strategy}
+}
+
+// Contributing returns the playlists whose tracks can end up in source,
+// leaving out those an Except only removes tracks of.
+func Contributing(source Source) []PlaylistSource {
+	switch s := source.(type) {
+	case ExceptSource:
+		return Contributing(s.From)
+	case UnionSource:
+		return contributing(s.Sources)
+	case IntersectSource:
+		return contributing(s.Sources)
+	case MedleySource:
+		if s.Source == nil {
+			return nil
+		}
+		return Contributing(s.Source)
+	}
+	return source.Playlists()
+}
+
+func contributing(sources []Source) []PlaylistSource {
+	var all []PlaylistSource
+	for _, source := range sources {
+		all = append(all, Contributing(source)...)
+	}
+	return all
+}
+
+func playlists(sources []Source) []PlaylistSource {
+	var all []PlaylistSource
+	for _, source := range sources {
+		all = append(all, source.Playlists()...)
+	}
+	return all
+}
+
+func uriSet(items []PlaylistItem) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item.Track.URI] = true
+	}
+	return set
+}
+
+// keep returns the items whose uri is (or, if in is false, isn't) in set.
+func keep(items []PlaylistItem, set map[string]bool, in bool) []PlaylistItem {
+	kept := make([]PlaylistItem, 0, len(items))
+	for _, item := range items {
+		if set[item.Track.URI] == in {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,89 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// IDs are 22 characters so GetID accepts them.
+const (
+	playlistA = "aaaaaaaaaaaaaaaaaaaaaa"
+	playlistB = "bbbbbbbbbbbbbbbbbbbbbb"
+	playlistC = "cccccccccccccccccccccc"
+)
+
+func newTestCache() *PlaylistCache {
+	cache := NewPlaylistCache(Spotify{})
+	cache.Put(playlistA, []PlaylistItem{{Track: Track{URI: "1"}}, {Track: Track{URI: "2"}}, {Track: Track{URI: "3"}}})
+	cache.Put(playlistB, []PlaylistItem{{Track: Track{URI: "3"}}, {Track: Track{URI: "4"}}, {Track: Track{URI: "2"}}})
+	cache.Put(playlistC, []PlaylistItem{{Track: Track{URI: "4"}}, {Track: Track{URI: "1"}}})
+	return cache
+}
+
+func uris(items []PlaylistItem) []string {
+	uris := make([]string, len(items))
+	for i, item := range items {
+		uris[i] = item.Track.URI
+	}
+	return uris
+}
+
+func TestUnionSource(t *testing.T) {
+	t.Run("returns unique tracks of every source in order", func(t *testing.T) {
+		source := NewPlaylistsSource([]string{playlistA, playlistB}, "", PlaylistSource{})
+		items, err := source.Evaluate(newTestCache())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1", "2", "3", "4"}, uris(items))
+		assert.Equal(t, playlistB, items[3].Source)
+	})
+
+	t.Run("returns tracks merged by weight", func(t *testing.T) {
+		source := UnionSource{
+			Merge: MergeWeighted,
+			Sources: []Source{
+				PlaylistSource{ID: playlistA},
+				PlaylistSource{ID: playlistC, Weight: 2},
+			},
+		}
+		items, err := source.Evaluate(newTestCache())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1", "4", "2", "3"}, uris(items))
+	})
+}
+
+func TestIntersectSource(t *testing.T) {
+	t.Run("returns tracks in every source", func(t *testing.T) {
+		source := IntersectSource{Sources: []Source{
+			PlaylistSource{ID: playlistA},
+			PlaylistSource{ID: playlistB},
+		}}
+		items, err := source.Evaluate(newTestCache())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"2", "3"}, uris(items))
+	})
+}
+
+func TestExceptSource(t *testing.T) {
+	t.Run("returns union minus removed tracks", func(t *testing.T) {
+		source := ExceptSource{
+			From:   NewPlaylistsSource([]string{playlistA, playlistB}, "", PlaylistSource{}),
+			Remove: []Source{PlaylistSource{ID: playlistC}},
+		}
+		items, err := source.Evaluate(newTestCache())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"2", "3"}, uris(items))
+		assert.Equal(t, []PlaylistSource{{ID: playlistA}, {ID: playlistB}, {ID: playlistC}}, source.Playlists())
+	})
+
+	t.Run("returns only playlists that contribute tracks", func(t *testing.T) {
+		source := UnionSource{Sources: []Source{
+			PlaylistSource{ID: playlistA},
+			ExceptSource{
+				From:   PlaylistSource{ID: playlistB},
+				Remove: []Source{PlaylistSource{ID: playlistC}},
+			},
+		}}
+		assert.Equal(t, []PlaylistSource{{ID: playlistA}, {ID: playlistB}}, Contributing(source))
+	})
+}
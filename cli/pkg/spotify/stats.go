@@ -0,0 +1,122 @@
+package spotify
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SourceStats is how much one source playlist gives a medley.
+type SourceStats struct {
+	ID string `json:"id"`
+	// Tracks is how many tracks the source has
+	Tracks int `json:"tracks"`
+	// Contributed is how many tracks of the medley were read from it
+	Contributed int `json:"contributed"`
+	// Unique is how many of its tracks no other source has
+	Unique int `json:"unique"`
+}
+
+// Overlap is how many tracks two sources share.
+type Overlap struct {
+	A       string  `json:"a"`
+	B       string  `json:"b"`
+	Shared  int     `json:"shared"`
+	Jaccard float64 `json:"jaccard"`
+}
+
+// ArtistCount is how many tracks of a medley are by an artist.
+type ArtistCount struct {
+	Name   string `json:"name"`
+	Tracks int    `json:"tracks"`
+}
+
+// Stats summarizes a medley and the sources it's made of.
+type Stats struct {
+	Tracks     int            `json:"tracks"`
+	DurationMs int            `json:"durationMs"`
+	Sources    []SourceStats  `json:"sources"`
+	Overlaps   []Overlap      `json:"overlaps"`
+	TopArtists []ArtistCount  `json:"topArtists"`
+	Decades    map[string]int `json:"decades"`
+}
+
+// ComputeStats summarizes medley, the tracks left after merging and
+// filtering the sources, which are given by id in ids order. Overlaps
+// has one entry per pair of sources and TopArtists at most top entries,
+// none if top isn't positive.
+func ComputeStats(ids []string, sources map[string][]PlaylistItem, medley []PlaylistItem, top int) Stats {
+	stats := Stats{Tracks: len(medley), Decades: make(map[string]int)}
+
+	sets := make(map[string]map[string]bool, len(ids))
+	// how many sources have each track
+	seenIn := make(map[string]int)
+	for _, id := range ids {
+		sets[id] = uriSet(sources[id])
+		for uri := range sets[id] {
+			seenIn[uri]++
+		}
+	}
+
+	contributed := make(map[string]int)
+	artists := make(map[string]int)
+	for _, item := range medley {
+		t := item.Track
+		contributed[item.Source]++
+		stats.DurationMs += t.DurationMs
+		if name := t.Artist(); name != "" {
+			artists[name]++
+		}
+		decade := "unknown"
+		if year, ok := releaseYear(t); ok {
+			decade = fmt.Sprintf("%ds", year/10*10)
+		}
+		stats.Decades[decade]++
+	}
+
+	for _, id := range ids {
+		unique := 0
+		for uri := range sets[id] {
+			if seenIn[uri] == 1 {
+				unique++
+			}
+		}
+		stats.Sources = append(stats.Sources, SourceStats{
+			ID:          id,
+			Tracks:      len(sets[id]),
+			Contributed: contributed[id],
+			Unique:      unique,
+		})
+	}
+
+	for i, a := range ids {
+		for _, b := range ids[i+1:] {
+			shared := 0
+			for uri := range sets[a] {
+				if sets[b][uri] {
+					shared++
+				}
+			}
+			overlap := Overlap{A: a, B: b, Shared: shared}
+			if union := len(sets[a]) + len(sets[b]) - shared; union > 0 {
+				overlap.Jaccard = float64(shared) / float64(union)
+			}
+			stats.Overlaps = append(stats.Overlaps, overlap)
+		}
+	}
+
+	for name, n := range artists {
+		stats.TopArtists = append(stats.TopArtists, ArtistCount{Name: name, Tracks: n})
+	}
+	// most tracks first, ties by name
+	sort.Slice(stats.TopArtists, func(i, j int) bool {
+		a, b := stats.TopArtists[i], stats.TopArtists[j]
+		if a.Tracks != b.Tracks {
+			return a.Tracks > b.Tracks
+		}
+		return a.Name < b.Name
+	})
+	if len(stats.TopArtists) > top {
+		stats.TopArtists = stats.TopArtists[:max(top, 0)]
+	}
+	return stats
+}
@@ -0,0 +1,63 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeStats(t *testing.T) {
+	track := func(uri, artist, date string, ms int) Track {
+		return Track{URI: uri, Artists: []Artist{{Name: artist}}, Album: &Album{ReleaseDate: date}, DurationMs: ms}
+	}
+	a := track("a", "X", "1994-05-01", 1000)
+	b := track("b", "Y", "2003", 2000)
+	c := track("c", "X", "", 3000)
+	d := track("d", "Z", "2011-01", 4000)
+	sources := map[string][]PlaylistItem{
+		"1": {{Track: a}, {Track: b}, {Track: c}},
+		"2": {{Track: b}, {Track: c}, {Track: d}},
+		"3": {},
+	}
+	medley := []PlaylistItem{
+		{Track: a, Source: "1"},
+		{Track: b, Source: "1"},
+		{Track: c, Source: "1"},
+		{Track: d, Source: "2"},
+	}
+
+	t.Run("returns what each source contributes", func(t *testing.T) {
+		stats := ComputeStats([]string{"1", "2", "3"}, sources, medley, 10)
+		assert.Equal(t, []SourceStats{
+			{ID: "1", Tracks: 3, Contributed: 3, Unique: 1},
+			{ID: "2", Tracks: 3, Contributed: 1, Unique: 1},
+			{ID: "3"},
+		}, stats.Sources)
+	})
+
+	t.Run("returns overlap of each pair", func(t *testing.T) {
+		stats := ComputeStats([]string{"1", "2", "3"}, sources, medley, 10)
+		assert.Equal(t, []Overlap{
+			{A: "1", B: "2", Shared: 2, Jaccard: 0.5},
+			{A: "1", B: "3"},
+			{A: "2", B: "3"},
+		}, stats.Overlaps)
+	})
+
+	t.Run("returns totals of the medley", func(t *testing.T) {
+		stats := ComputeStats([]string{"1", "2"}, sources, medley, 10)
+		assert.Equal(t, 4, stats.Tracks)
+		assert.Equal(t, 10000, stats.DurationMs)
+		assert.Equal(t, map[string]int{"1990s": 1, "2000s": 1, "2010s": 1, "unknown": 1}, stats.Decades)
+	})
+
+	t.Run("returns top artists", func(t *testing.T) {
+		stats := ComputeStats([]string{"1", "2"}, sources, medley, 2)
+		assert.Equal(t, []ArtistCount{{Name: "X", Tracks: 2}, {Name: "Y", Tracks: 1}}, stats.TopArtists)
+	})
+
+	t.Run("returns no top artists for negative top", func(t *testing.T) {
+		stats := ComputeStats([]string{"1", "2"}, sources, medley, -1)
+		assert.Empty(t, stats.TopArtists)
+	})
+}
@@ -1,5 +1,98 @@
 package spotify
 
+import "strings"
+
 type Track struct {
+	URI         string       `json:"uri"`
+	Name        string       `json:"name,omitempty"`
+	Artists     []Artist     `json:"artists,omitempty"`
+	Album       *Album       `json:"album,omitempty"`
+	DurationMs  int          `json:"duration_ms,omitempty"`
+	Popularity  int          `json:"popularity,omitempty"`
+	Explicit    bool         `json:"explicit,omitempty"`
+	ExternalIDs *ExternalIDs `json:"external_ids,omitempty"`
+	// LinkedFrom is the track in the playlist when Spotify relinked
+	// it to another release that is playable in the market asked for.
+	LinkedFrom *LinkedTrack `json:"linked_from,omitempty"`
+}
+
+type Artist struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type Album struct {
+	Name        string `json:"name"`
+	AlbumType   string `json:"album_type"`
+	ReleaseDate string `json:"release_date"`
+}
+
+type ExternalIDs struct {
+	ISRC string `json:"isrc"`
+}
+
+type LinkedTrack struct {
+	ID  string `json:"id"`
 	URI string `json:"uri"`
 }
+
+type User struct {
+	ID string `json:"id"`
+}
+
+// PlaylistItem is a track in a playlist along with
+// who added it and when.
+type PlaylistItem struct {
+	AddedAt string `json:"added_at"`
+	AddedBy User   `json:"added_by"`
+	Track   Track  `json:"track"`
+	// Source is the ID of the playlist the item was read from
+	// when it is used as a source of a medley.
+	Source string `json:"-"`
+}
+
+// unlink gives relinked tracks back the URI they have in the playlist,
+// so they can be told apart from, and removed like, any other track.
+func unlink(items []PlaylistItem) []PlaylistItem {
+	for i, item := range items {
+		if item.Track.LinkedFrom != nil && item.Track.LinkedFrom.URI != "" {
+			items[i].Track.URI = item.Track.LinkedFrom.URI
+		}
+	}
+	return items
+}
+
+// ReleaseDate returns the release date of the track's album, which is
+// "YYYY", "YYYY-MM" or "YYYY-MM-DD" depending on its precision.
+func (t Track) ReleaseDate() string {
+	if t.Album == nil {
+		return ""
+	}
+	return t.Album.ReleaseDate
+}
+
+// ArtistNames returns the names of the track's artists.
+func (t Track) ArtistNames() []string {
+	names := make([]string, len(t.Artists))
+	for i, a := range t.Artists {
+		names[i] = a.Name
+	}
+	return names
+}
+
+// Artist returns the name of the track's first artist.
+func (t Track) Artist() string {
+	if len(t.Artists) == 0 {
+		return ""
+	}
+	return t.Artists[0].Name
+}
+
+// String returns the track as "Artist - Name", or its uri
+// if its metadata wasn't fetched.
+func (t Track) String() string {
+	if t.Name == "" {
+		return t.URI
+	}
+	return strings.Join(t.ArtistNames(), ", ") + " - " + t.Name
+}
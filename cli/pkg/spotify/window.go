@@ -0,0 +1,51 @@
+package spotify
+
+import (
+	"fmt"
+	"time"
+)
+
+// AddedSince returns the items added at or after since.
+func AddedSince(items []PlaylistItem, since time.Time) []PlaylistItem {
+	recent := make([]PlaylistItem, 0, len(items))
+	for _, item := range items {
+		if !item.AddedTime().Before(since) {
+			recent = append(recent, item)
+		}
+	}
+	return recent
+}
+
+// AddedTime returns when the item was added to its playlist. Items without a
+// date, added before spotify kept track of it, are the oldest.
+func (item PlaylistItem) AddedTime() time.Time {
+	t, err := time.Parse(time.RFC3339, item.AddedAt)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// isOrdered reports whether items are ordered by when they were added,
+// newest first if newestFirst is set and oldest first otherwise.
+func isOrdered(items []PlaylistItem, newestFirst bool) bool {
+	for i := 1; i < len(items); i++ {
+		prev, cur := items[i-1].AddedTime(), items[i].AddedTime()
+		if newestFirst && cur.After(prev) || !newestFirst && cur.Before(prev) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseSince parses a date ("2024-07-01") or time (RFC 3339).
+func parseSince(since string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, since); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid addedSince %q, expected YYYY-MM-DD or RFC 3339", since)
+	}
+	return t, nil
+}
@@ -0,0 +1,48 @@
+package spotify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func dated(dates ...string) []PlaylistItem {
+	items := make([]PlaylistItem, len(dates))
+	for i, date := range dates {
+		items[i] = PlaylistItem{AddedAt: date + "T00:00:00Z", Track: Track{URI: date}}
+	}
+	return items
+}
+
+func TestAddedSince(t *testing.T) {
+	t.Run("returns items added at or after since", func(t *testing.T) {
+		since := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+		recent := AddedSince(dated("2024-06-30", "2024-07-01", "2024-07-02"), since)
+		assert.Equal(t, []string{"2024-07-01", "2024-07-02"}, uris(recent))
+	})
+}
+
+func TestIsOrdered(t *testing.T) {
+	t.Run("returns whether items are oldest or newest first", func(t *testing.T) {
+		items := dated("2024-06-30", "2024-07-01", "2024-07-01")
+		assert.True(t, isOrdered(items, false))
+		assert.False(t, isOrdered(items, true))
+	})
+}
+
+func TestPlaylistSourceSince(t *testing.T) {
+	now := time.Date(2024, 7, 10, 0, 0, 0, 0, time.UTC)
+
+	t.Run("returns later of window and date", func(t *testing.T) {
+		source := PlaylistSource{AddedSince: "2024-07-05"}
+		since, err := source.Since(now)
+		assert.Nil(t, err)
+		assert.Equal(t, time.Date(2024, 7, 5, 0, 0, 0, 0, time.UTC), since)
+	})
+
+	t.Run("returns error for invalid date", func(t *testing.T) {
+		_, err := PlaylistSource{AddedSince: "last week"}.Since(now)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,136 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/mhborthwick/medley/cli/pkg/spotify"
+)
+
+// Kinds of journaled operations.
+const (
+	// OpCreate creates the destination playlist.
+	OpCreate = "create"
+	// OpAdd adds a batch of tracks to the destination.
+	OpAdd = "add"
+	// OpRemove removes a batch of tracks from the destination.
+	OpRemove = "remove"
+	// OpArchive appends tracks to an archive playlist.
+	OpArchive = "archive"
+	// OpReorder moves the tracks of the destination into order,
+	// or only its pinned tracks if it has no order.
+	OpReorder = "reorder"
+	// OpRestoreOrder moves the tracks of the destination into
+	// exactly the order of URIs, repeats included.
+	OpRestoreOrder = "restoreOrder"
+	// OpRemovePositions removes the tracks at Positions of the
+	// destination as it was at SnapshotID.
+	OpRemovePositions = "removePositions"
+)
+
+// journalIDLayout names journals by when they were started.
+const journalIDLayout = "20060102T150405.000000000Z"
+
+// Op is a single step of an apply, one of the Op kinds.
+type Op struct {
+	Kind string   `json:"kind"`
+	URIs []string `json:"uris,omitempty"`
+	// Sources are the source playlists of added tracks, by uri.
+	Sources map[string]string `json:"sources,omitempty"`
+	// Owned are the added tracks recorded as medley's,
+	// all of them except for some a restore adds back.
+	Owned   []string `json:"owned,omitempty"`
+	Prepend bool     `json:"prepend,omitempty"`
+	// Pins are the pinned tracks of a reorder op.
+	Pins []spotify.Pin `json:"pins,omitempty"`
+	// Playlist is the archive playlist of an archive op.
+	Playlist string `json:"playlist,omitempty"`
+	// Positions are the tracks a removePositions op removes, at their
+	// positions in the destination when it was at SnapshotID and held
+	// Size tracks. The snapshot is set once the op before has run.
+	Positions  []spotify.PositionedTrack `json:"positions,omitempty"`
+	SnapshotID string                    `json:"snapshotID,omitempty"`
+	Size       int                       `json:"size,omitempty"`
+	Done       bool                      `json:"done"`
+}
+
+// Journal records the planned operations of a create, sync, restore or
+// dedupe and which of them completed, so an interrupted run can be
+// finished later.
+type Journal struct {
+	ID      string `json:"id"`
+	Command string `json:"command"`
+	// Path is the config file the run was planned from,
+	// or the snapshot a restore puts back.
+	Path string `json:"path"`
+	// UserID creates the destination, for journals that do.
+	UserID string `json:"userID,omitempty"`
+	// Destination is empty until an OpCreate has run.
+	Destination string    `json:"destination"`
+	StartedAt   time.Time `json:"startedAt"`
+	Ops         []Op      `json:"ops"`
+}
+
+// NewJournal returns an empty journal for a run started at the given time.
+func NewJournal(command, path, destination string, at time.Time) *Journal {
+	return &Journal{
+		ID:          at.UTC().Format(journalIDLayout),
+		Command:     command,
+		Path:        path,
+		Destination: destination,
+		StartedAt:   at,
+	}
+}
+
+// Save writes the journal to dir.
+func (j *Journal) Save(dir string) error {
+	journals := filepath.Join(dir, "journals")
+	if err := os.MkdirAll(journals, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(journals, j.ID+".json.tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(journals, j.ID+".json"))
+}
+
+// Finish removes the journal from dir once every operation is done.
+func (j *Journal) Finish(dir string) error {
+	err := os.Remove(filepath.Join(dir, "journals", j.ID+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Pending returns the journals in dir of runs that didn't finish,
+// oldest first.
+func Pending(dir string) ([]*Journal, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "journals", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(paths)
+	journals := make([]*Journal, 0, len(paths))
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		var j Journal
+		if err := json.Unmarshal(data, &j); err != nil {
+			return nil, err
+		}
+		journals = append(journals, &j)
+	}
+	return journals, nil
+}
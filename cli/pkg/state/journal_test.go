@@ -0,0 +1,37 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPending(t *testing.T) {
+	t.Run("returns unfinished journals oldest first", func(t *testing.T) {
+		dir := t.TempDir()
+		later := NewJournal("sync", "medley.pkl", "abc", time.Date(2024, 7, 2, 0, 0, 0, 0, time.UTC))
+		earlier := NewJournal("create", "medley.pkl", "", time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC))
+		earlier.Ops = []Op{{Kind: OpCreate, Done: true}, {Kind: OpAdd, URIs: []string{"123"}}}
+		assert.Nil(t, later.Save(dir))
+		assert.Nil(t, earlier.Save(dir))
+
+		pending, err := Pending(dir)
+		assert.Nil(t, err)
+		assert.Len(t, pending, 2)
+		assert.Equal(t, "create", pending[0].Command)
+		assert.True(t, pending[0].Ops[0].Done)
+		assert.Equal(t, []string{"123"}, pending[0].Ops[1].URIs)
+		assert.Equal(t, "abc", pending[1].Destination)
+	})
+
+	t.Run("forgets finished journals", func(t *testing.T) {
+		dir := t.TempDir()
+		j := NewJournal("sync", "medley.pkl", "abc", time.Now())
+		assert.Nil(t, j.Save(dir))
+		assert.Nil(t, j.Finish(dir))
+		pending, err := Pending(dir)
+		assert.Nil(t, err)
+		assert.Empty(t, pending)
+	})
+}
@@ -0,0 +1,122 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mhborthwick/medley/cli/pkg/spotify"
+)
+
+// snapshotIDLayout names snapshots by when they were taken,
+// so they sort oldest first.
+const snapshotIDLayout = "20060102T150405.000000000Z"
+
+// KeptSnapshots is how many snapshots of each destination are kept,
+// older ones are removed as new ones are saved.
+const KeptSnapshots = 20
+
+// Snapshot is the full, ordered contents of a destination
+// saved before medley changed it.
+type Snapshot struct {
+	ID          string                 `json:"id"`
+	Destination string                 `json:"destination"`
+	TakenAt     time.Time              `json:"takenAt"`
+	SnapshotID  string                 `json:"snapshotID"`
+	Items       []spotify.PlaylistItem `json:"items"`
+	// Owned are the uris of items medley had added itself.
+	Owned []string `json:"owned,omitempty"`
+}
+
+// URIs returns the uris of the snapshot's tracks, in playlist order.
+func (s Snapshot) URIs() []string {
+	uris := make([]string, len(s.Items))
+	for i, item := range s.Items {
+		uris[i] = item.Track.URI
+	}
+	return uris
+}
+
+// SaveSnapshot saves items, the contents of destination at snapshotID,
+// as a new snapshot taken at the given time, along with which of them
+// medley owned. Only the last KeptSnapshots snapshots are kept.
+func SaveSnapshot(dir, destination, snapshotID string, items []spotify.PlaylistItem, owned []string, at time.Time) (Snapshot, error) {
+	s := Snapshot{
+		ID:          at.UTC().Format(snapshotIDLayout),
+		Destination: destination,
+		TakenAt:     at,
+		SnapshotID:  snapshotID,
+		Items:       items,
+		Owned:       owned,
+	}
+	snapshots := snapshotDir(dir, destination)
+	if err := os.MkdirAll(snapshots, 0o755); err != nil {
+		return Snapshot{}, err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return Snapshot{}, err
+	}
+	tmp := filepath.Join(snapshots, s.ID+".json.tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return Snapshot{}, err
+	}
+	if err := os.Rename(tmp, filepath.Join(snapshots, s.ID+".json")); err != nil {
+		return Snapshot{}, err
+	}
+	paths, err := filepath.Glob(filepath.Join(snapshots, "*.json"))
+	if err != nil {
+		return Snapshot{}, err
+	}
+	slices.Sort(paths)
+	for len(paths) > KeptSnapshots {
+		if err := os.Remove(paths[0]); err != nil {
+			return Snapshot{}, err
+		}
+		paths = paths[1:]
+	}
+	return s, nil
+}
+
+// Snapshots returns the snapshots of destination, oldest first.
+func Snapshots(dir, destination string) ([]Snapshot, error) {
+	paths, err := filepath.Glob(filepath.Join(snapshotDir(dir, destination), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(paths)
+	snapshots := make([]Snapshot, 0, len(paths))
+	for _, p := range paths {
+		s, err := LoadSnapshot(dir, destination, strings.TrimSuffix(filepath.Base(p), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, nil
+}
+
+// LoadSnapshot reads the snapshot of destination with the given id.
+func LoadSnapshot(dir, destination, id string) (Snapshot, error) {
+	data, err := os.ReadFile(filepath.Join(snapshotDir(dir, destination), id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return Snapshot{}, fmt.Errorf("no snapshot %s of playlist %s", id, destination)
+	}
+	if err != nil {
+		return Snapshot{}, err
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return Snapshot{}, err
+	}
+	return s, nil
+}
+
+func snapshotDir(dir, destination string) string {
+	return filepath.Join(dir, "snapshots", destination)
+}
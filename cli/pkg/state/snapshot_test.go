@@ -0,0 +1,78 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mhborthwick/medley/cli/pkg/spotify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshots(t *testing.T) {
+	t.Run("returns saved snapshots oldest first", func(t *testing.T) {
+		dir := t.TempDir()
+		items := []spotify.PlaylistItem{
+			{Track: spotify.Track{URI: "2"}},
+			{Track: spotify.Track{URI: "1"}},
+		}
+		later := time.Date(2024, 7, 2, 0, 0, 0, 0, time.UTC)
+		earlier := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+		_, err := SaveSnapshot(dir, "abc", "snap2", items, []string{"1"}, later)
+		assert.Nil(t, err)
+		_, err = SaveSnapshot(dir, "abc", "snap1", items[:1], nil, earlier)
+		assert.Nil(t, err)
+
+		snapshots, err := Snapshots(dir, "abc")
+		assert.Nil(t, err)
+		assert.Len(t, snapshots, 2)
+		assert.Equal(t, "20240701T000000.000000000Z", snapshots[0].ID)
+		assert.Equal(t, "snap1", snapshots[0].SnapshotID)
+		assert.Equal(t, []string{"2", "1"}, snapshots[1].URIs())
+		assert.Equal(t, []string{"1"}, snapshots[1].Owned)
+	})
+
+	t.Run("keeps snapshots taken within a second apart", func(t *testing.T) {
+		dir := t.TempDir()
+		at := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+		_, err := SaveSnapshot(dir, "abc", "snap1", nil, nil, at)
+		assert.Nil(t, err)
+		_, err = SaveSnapshot(dir, "abc", "snap2", nil, nil, at.Add(time.Millisecond))
+		assert.Nil(t, err)
+		snapshots, err := Snapshots(dir, "abc")
+		assert.Nil(t, err)
+		assert.Len(t, snapshots, 2)
+	})
+
+	t.Run("keeps only the last snapshots", func(t *testing.T) {
+		dir := t.TempDir()
+		at := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+		for i := range KeptSnapshots + 2 {
+			_, err := SaveSnapshot(dir, "abc", "snap", nil, nil, at.Add(time.Duration(i)*time.Hour))
+			assert.Nil(t, err)
+		}
+		snapshots, err := Snapshots(dir, "abc")
+		assert.Nil(t, err)
+		assert.Len(t, snapshots, KeptSnapshots)
+		assert.Equal(t, at.Add(2*time.Hour), snapshots[0].TakenAt)
+	})
+
+	t.Run("returns no snapshots for new destination", func(t *testing.T) {
+		snapshots, err := Snapshots(t.TempDir(), "abc")
+		assert.Nil(t, err)
+		assert.Empty(t, snapshots)
+	})
+
+	t.Run("returns error for unknown snapshot", func(t *testing.T) {
+		_, err := LoadSnapshot(t.TempDir(), "abc", "20240701T000000Z")
+		assert.EqualError(t, err, "no snapshot 20240701T000000Z of playlist abc")
+	})
+
+	t.Run("doesn't show up as a destination", func(t *testing.T) {
+		dir := t.TempDir()
+		_, err := SaveSnapshot(dir, "abc", "snap", nil, nil, time.Now())
+		assert.Nil(t, err)
+		all, err := LoadAll(dir)
+		assert.Nil(t, err)
+		assert.Empty(t, all)
+	})
+}
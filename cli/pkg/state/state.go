@@ -0,0 +1,185 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mhborthwick/medley/cli/pkg/spotify"
+)
+
+// Entry is what medley knows about a track it added to a destination.
+type Entry struct {
+	// Source is the ID of the source playlist that contributed the
+	// track. It is empty for tracks adopted from an existing playlist.
+	Source  string    `json:"source"`
+	AddedAt time.Time `json:"addedAt"`
+}
+
+// Source is the last seen version of a source playlist.
+type Source struct {
+	SnapshotID string                 `json:"snapshotID"`
+	Items      []spotify.PlaylistItem `json:"items"`
+	// Market is the market the items were fetched for,
+	// relinked tracks differ from those fetched without
+	Market string `json:"market,omitempty"`
+}
+
+// Reusable reports whether the items can be used instead of fetching
+// the source at snapshotID for market. State saved before items were
+// kept has none.
+func (s Source) Reusable(snapshotID, market string) bool {
+	return s.SnapshotID == snapshotID && s.Market == market && len(s.Items) > 0
+}
+
+// State records, per destination playlist, which tracks medley added
+// itself and where they came from. Tracks missing from it were added
+// by hand and are never removed by sync.
+type State struct {
+	Destination string            `json:"destination"`
+	Tracks      map[string]Entry  `json:"tracks"`
+	Sources     map[string]Source `json:"sources"`
+	// Expired records when tracks were expired from the destination,
+	// so they aren't added back while still in a source.
+	Expired map[string]time.Time `json:"expired"`
+	// Archives are the monthly archive playlists created
+	// for the destination, by name, so each is made once.
+	Archives map[string]string `json:"archives"`
+}
+
+// Dir returns the directory state files are kept in. It can be
+// overridden with MEDLEY_STATE_DIR.
+func Dir() (string, error) {
+	if dir := os.Getenv("MEDLEY_STATE_DIR"); dir != "" {
+		return dir, nil
+	}
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(config, "medley", "state"), nil
+}
+
+// Load reads the state for a destination from dir. If no state has been
+// saved yet, it returns an empty state and false.
+func Load(dir, destination string) (*State, bool, error) {
+	s := &State{
+		Destination: destination,
+		Tracks:      make(map[string]Entry),
+		Sources:     make(map[string]Source),
+		Expired:     make(map[string]time.Time),
+		Archives:    make(map[string]string),
+	}
+	data, err := os.ReadFile(path(dir, destination))
+	if errors.Is(err, os.ErrNotExist) {
+		return s, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, false, err
+	}
+	if s.Tracks == nil {
+		s.Tracks = make(map[string]Entry)
+	}
+	if s.Sources == nil {
+		s.Sources = make(map[string]Source)
+	}
+	if s.Expired == nil {
+		s.Expired = make(map[string]time.Time)
+	}
+	if s.Archives == nil {
+		s.Archives = make(map[string]string)
+	}
+	return s, true, nil
+}
+
+// LoadAll reads the state of every destination saved in dir.
+func LoadAll(dir string) ([]*State, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	all := make([]*State, 0, len(paths))
+	for _, p := range paths {
+		destination := strings.TrimSuffix(filepath.Base(p), ".json")
+		s, _, err := Load(dir, destination)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, s)
+	}
+	return all, nil
+}
+
+// Save writes the state to dir, replacing any previous state for the
+// same destination.
+func (s *State) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write to a temp file first so an interrupted
+	// save never leaves a truncated state behind
+	tmp := path(dir, s.Destination) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path(dir, s.Destination))
+}
+
+// Add records uri as added by medley from source at the given time.
+func (s *State) Add(uri, source string, at time.Time) {
+	s.Tracks[uri] = Entry{Source: source, AddedAt: at}
+}
+
+// Remove forgets uris, e.g. after sync removed them.
+func (s *State) Remove(uris []string) {
+	for _, uri := range uris {
+		delete(s.Tracks, uri)
+	}
+}
+
+// Owns reports whether medley added uri to the destination.
+func (s *State) Owns(uri string) bool {
+	_, ok := s.Tracks[uri]
+	return ok
+}
+
+// OwnedOf returns the uris of items medley added itself, once each.
+func (s *State) OwnedOf(items []spotify.PlaylistItem) []string {
+	var owned []string
+	seen := make(map[string]bool)
+	for _, item := range items {
+		uri := item.Track.URI
+		if s.Owns(uri) && !seen[uri] {
+			seen[uri] = true
+			owned = append(owned, uri)
+		}
+	}
+	return owned
+}
+
+// Expire records uris as expired at the given time.
+func (s *State) Expire(uris []string, at time.Time) {
+	for _, uri := range uris {
+		s.Expired[uri] = at
+	}
+}
+
+// IsExpired reports whether uri was expired from the destination.
+func (s *State) IsExpired(uri string) bool {
+	_, ok := s.Expired[uri]
+	return ok
+}
+
+func path(dir, destination string) string {
+	return filepath.Join(dir, destination+".json")
+}
@@ -0,0 +1,103 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mhborthwick/medley/cli/pkg/spotify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("returns empty state and false", func(t *testing.T) {
+		s, exists, err := Load(t.TempDir(), "abc")
+		assert.Nil(t, err)
+		assert.False(t, exists)
+		assert.Equal(t, "abc", s.Destination)
+		assert.Empty(t, s.Tracks)
+	})
+
+	t.Run("returns saved state and true", func(t *testing.T) {
+		dir := t.TempDir()
+		s, _, _ := Load(dir, "abc")
+		s.Add("123", "src", time.Now())
+		s.Add("456", "src", time.Now())
+		s.Sources["src"] = Source{SnapshotID: "snap", Market: "from_token", Items: []spotify.PlaylistItem{
+			{Track: spotify.Track{URI: "123", Name: "abc"}},
+		}}
+		s.Archives["Archive July 2024"] = "archive"
+		assert.Nil(t, s.Save(dir))
+		loaded, exists, err := Load(dir, "abc")
+		assert.Nil(t, err)
+		assert.True(t, exists)
+		assert.True(t, loaded.Owns("123"))
+		assert.True(t, loaded.Owns("456"))
+		assert.Equal(t, "src", loaded.Tracks["123"].Source)
+		assert.Equal(t, "snap", loaded.Sources["src"].SnapshotID)
+		assert.Equal(t, "abc", loaded.Sources["src"].Items[0].Track.Name)
+		assert.Equal(t, "from_token", loaded.Sources["src"].Market)
+		assert.Equal(t, "archive", loaded.Archives["Archive July 2024"])
+	})
+}
+
+func TestLoadAll(t *testing.T) {
+	t.Run("returns every destination", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, destination := range []string{"abc", "def"} {
+			s, _, _ := Load(dir, destination)
+			assert.Nil(t, s.Save(dir))
+		}
+		all, err := LoadAll(dir)
+		assert.Nil(t, err)
+		assert.Len(t, all, 2)
+		assert.Equal(t, "abc", all[0].Destination)
+		assert.Equal(t, "def", all[1].Destination)
+	})
+}
+
+func TestRemove(t *testing.T) {
+	t.Run("forgets uris", func(t *testing.T) {
+		s, _, _ := Load(t.TempDir(), "abc")
+		s.Add("123", "src", time.Now())
+		s.Add("456", "src", time.Now())
+		s.Remove([]string{"123"})
+		assert.False(t, s.Owns("123"))
+		assert.True(t, s.Owns("456"))
+	})
+}
+
+func TestExpire(t *testing.T) {
+	t.Run("remembers expired uris across saves", func(t *testing.T) {
+		dir := t.TempDir()
+		s, _, _ := Load(dir, "abc")
+		s.Expire([]string{"123"}, time.Now())
+		assert.Nil(t, s.Save(dir))
+		loaded, _, err := Load(dir, "abc")
+		assert.Nil(t, err)
+		assert.True(t, loaded.IsExpired("123"))
+		assert.False(t, loaded.IsExpired("456"))
+	})
+}
+
+func TestSourceReusable(t *testing.T) {
+	src := Source{SnapshotID: "snap", Market: "from_token", Items: []spotify.PlaylistItem{
+		{Track: spotify.Track{URI: "123"}},
+	}}
+
+	t.Run("returns true at the same snapshot and market", func(t *testing.T) {
+		assert.True(t, src.Reusable("snap", "from_token"))
+	})
+
+	t.Run("returns false at another snapshot", func(t *testing.T) {
+		assert.False(t, src.Reusable("other", "from_token"))
+	})
+
+	t.Run("returns false for another market", func(t *testing.T) {
+		assert.False(t, src.Reusable("snap", ""))
+		assert.False(t, src.Reusable("snap", "US"))
+	})
+
+	t.Run("returns false without items", func(t *testing.T) {
+		assert.False(t, Source{SnapshotID: "snap"}.Reusable("snap", ""))
+	})
+}